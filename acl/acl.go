@@ -0,0 +1,234 @@
+// Package acl filters the connections accepted by a net.Listener with an
+// ordered list of allow/deny rules on the address of the peer.
+package acl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"github.com/midbel/ipaddr"
+)
+
+type Action int8
+
+const (
+	Deny Action = iota
+	Allow
+)
+
+func (a Action) String() string {
+	switch a {
+	case Allow:
+		return "allow"
+	case Deny:
+		return "deny"
+	default:
+		return "<unknown>"
+	}
+}
+
+type Rule struct {
+	Net    ipaddr.Net
+	Action Action
+}
+
+func (r Rule) String() string {
+	return fmt.Sprintf("%s %s", r.Action, r.Net)
+}
+
+type Stats struct {
+	Rule     Rule
+	Default  bool
+	Accepted uint64
+	Rejected uint64
+}
+
+// Ruleset evaluates rules in order: the first rule covering an address gives
+// the action to apply. A Ruleset is immutable once created and safe for
+// concurrent use.
+type Ruleset struct {
+	rules   []Rule
+	policy  Action
+	counter []uint64
+}
+
+func NewRuleset(policy Action, rules ...Rule) *Ruleset {
+	list := make([]Rule, len(rules))
+	copy(list, rules)
+	return &Ruleset{
+		rules:   list,
+		policy:  policy,
+		counter: make([]uint64, 2*(len(list)+1)),
+	}
+}
+
+// ParseRuleset reads one rule per line written as "allow <net>" or "deny
+// <net>" and the default policy as "default allow" or "default deny". Empty
+// lines and lines starting with # are ignored.
+func ParseRuleset(r io.Reader) (*Ruleset, error) {
+	var (
+		rules  []Rule
+		policy = Deny
+		scan   = bufio.NewScanner(r)
+	)
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s: expected action and network", line)
+		}
+		if fields[0] == "default" {
+			action, err := parseAction(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", line, err)
+			}
+			policy = action
+			continue
+		}
+		action, err := parseAction(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", line, err)
+		}
+		nw, err := ipaddr.ParseNet(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", line, err)
+		}
+		rules = append(rules, Rule{Net: nw, Action: action})
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+	return NewRuleset(policy, rules...), nil
+}
+
+func parseAction(str string) (Action, error) {
+	switch str {
+	case "allow", "permit":
+		return Allow, nil
+	case "deny":
+		return Deny, nil
+	default:
+		return Deny, fmt.Errorf("unknown action %s", str)
+	}
+}
+
+func (r *Ruleset) Rules() []Rule {
+	list := make([]Rule, len(r.rules))
+	copy(list, r.rules)
+	return list
+}
+
+func (r *Ruleset) Policy() Action {
+	return r.policy
+}
+
+func (r *Ruleset) Allow(ip ipaddr.IP) bool {
+	return r.count(r.index(ip))
+}
+
+func (r *Ruleset) Stats() []Stats {
+	list := make([]Stats, 0, len(r.rules)+1)
+	for i := 0; i <= len(r.rules); i++ {
+		s := Stats{
+			Accepted: atomic.LoadUint64(&r.counter[2*i]),
+			Rejected: atomic.LoadUint64(&r.counter[2*i+1]),
+		}
+		if i < len(r.rules) {
+			s.Rule = r.rules[i]
+		} else {
+			s.Rule.Action, s.Default = r.policy, true
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+func (r *Ruleset) index(ip ipaddr.IP) int {
+	for i, rule := range r.rules {
+		if rule.Net.Address().Is6() == ip.Is6() && rule.Net.Contains(ip) {
+			return i
+		}
+	}
+	return len(r.rules)
+}
+
+func (r *Ruleset) count(x int) bool {
+	action := r.policy
+	if x < len(r.rules) {
+		action = r.rules[x].Action
+	}
+	if action == Allow {
+		atomic.AddUint64(&r.counter[2*x], 1)
+		return true
+	}
+	atomic.AddUint64(&r.counter[2*x+1], 1)
+	return false
+}
+
+// Listener closes the connections rejected by its Ruleset as soon as they
+// are accepted. The Ruleset can be replaced while Accept is running. A nil
+// Ruleset rejects every connection.
+type Listener struct {
+	net.Listener
+	rules atomic.Value
+}
+
+func Listen(inner net.Listener, rules *Ruleset) *Listener {
+	ln := Listener{Listener: inner}
+	ln.SetRules(rules)
+	return &ln
+}
+
+func (l *Listener) Rules() *Ruleset {
+	return l.rules.Load().(*Ruleset)
+}
+
+func (l *Listener) SetRules(rules *Ruleset) {
+	if rules == nil {
+		rules = NewRuleset(Deny)
+	}
+	l.rules.Store(rules)
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		var (
+			rules = l.Rules()
+			x     = len(rules.rules)
+		)
+		if ip, ok := remoteIP(c.RemoteAddr()); ok {
+			x = rules.index(ip)
+		}
+		if rules.count(x) {
+			return c, nil
+		}
+		c.Close()
+	}
+}
+
+func remoteIP(addr net.Addr) (ipaddr.IP, bool) {
+	var std net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		std = a.IP
+	case *net.UDPAddr:
+		std = a.IP
+	case *net.IPAddr:
+		std = a.IP
+	default:
+		return ipaddr.Zero, false
+	}
+	ip, err := ipaddr.FromStdIP(std)
+	return ip, err == nil
+}
@@ -0,0 +1,150 @@
+package acl
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/midbel/ipaddr"
+)
+
+const rules = `
+# internal networks
+deny  10.1.0.0/16
+allow 10.0.0.0/8
+allow 2001:db8::/32
+default deny
+`
+
+func TestRuleset(t *testing.T) {
+	rs, err := ParseRuleset(strings.NewReader(rules))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	data := []struct {
+		Addr  string
+		Allow bool
+	}{
+		{Addr: "10.1.2.3", Allow: false},
+		{Addr: "10.2.3.4", Allow: true},
+		{Addr: "10.3.4.5", Allow: true},
+		{Addr: "2001:db8::1", Allow: true},
+		{Addr: "192.0.2.1", Allow: false},
+		{Addr: "2001:db9::1", Allow: false},
+	}
+	for _, d := range data {
+		ip, _ := ipaddr.ParseIP(d.Addr)
+		if got := rs.Allow(ip); got != d.Allow {
+			t.Errorf("%s: result mismatched! want %t, got %t", d.Addr, d.Allow, got)
+		}
+	}
+	want := []Stats{
+		{Rejected: 1},
+		{Accepted: 2},
+		{Accepted: 1},
+		{Rejected: 2, Default: true},
+	}
+	stats := rs.Stats()
+	if len(stats) != len(want) {
+		t.Fatalf("stats length mismatched! want %d, got %d", len(want), len(stats))
+	}
+	for i, s := range stats {
+		w := want[i]
+		if s.Accepted != w.Accepted || s.Rejected != w.Rejected || s.Default != w.Default {
+			t.Errorf("%d: stats mismatched! want %+v, got %+v", i, w, s)
+		}
+	}
+	if stats[0].Rule.String() != "deny 10.1.0.0/16" {
+		t.Errorf("rule mismatched! got %s", stats[0].Rule)
+	}
+
+	mixed := []struct {
+		Rules string
+		Addr  string
+		Allow bool
+	}{
+		{Rules: "deny ::/0\ndefault allow", Addr: "192.0.2.1", Allow: true},
+		{Rules: "deny ::/0\ndefault allow", Addr: "2001:db8::1", Allow: false},
+		{Rules: "allow 0.0.0.0/0\ndefault deny", Addr: "2001:db8::1", Allow: false},
+		{Rules: "allow 0.0.0.0/0\ndefault deny", Addr: "192.0.2.1", Allow: true},
+	}
+	for _, d := range mixed {
+		rs, err := ParseRuleset(strings.NewReader(d.Rules))
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", d.Rules, err)
+		}
+		ip, _ := ipaddr.ParseIP(d.Addr)
+		if got := rs.Allow(ip); got != d.Allow {
+			t.Errorf("%q/%s: result mismatched! want %t, got %t", d.Rules, d.Addr, d.Allow, got)
+		}
+	}
+
+	for _, str := range []string{"reject 10.0.0.0/8", "allow 10.0.0.0", "default maybe", "allow"} {
+		if _, err := ParseRuleset(strings.NewReader(str)); err == nil {
+			t.Errorf("%s: expected error", str)
+		}
+	}
+}
+
+func TestListener(t *testing.T) {
+	inner, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("can not listen: %s", err)
+	}
+	defer inner.Close()
+
+	loopback, _ := ipaddr.ParseNet("127.0.0.0/8")
+	var (
+		deny  = NewRuleset(Allow, Rule{Net: loopback, Action: Deny})
+		allow = NewRuleset(Deny, Rule{Net: loopback, Action: Allow})
+		ln    = Listen(inner, deny)
+		conns = make(chan net.Conn)
+	)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				close(conns)
+				return
+			}
+			conns <- c
+		}
+	}()
+
+	c, err := net.Dial("tcp4", inner.Addr().String())
+	if err != nil {
+		t.Fatalf("fail to dial: %s", err)
+	}
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c.Read(make([]byte, 1)); err == nil {
+		t.Errorf("connection should have been closed")
+	}
+	c.Close()
+
+	ln.SetRules(allow)
+	if c, err = net.Dial("tcp4", inner.Addr().String()); err != nil {
+		t.Fatalf("fail to dial: %s", err)
+	}
+	defer c.Close()
+	select {
+	case s := <-conns:
+		s.Close()
+	case <-time.After(time.Second):
+		t.Fatalf("connection not accepted")
+	}
+	if s := deny.Stats()[0]; s.Rejected != 1 || s.Accepted != 0 {
+		t.Errorf("deny stats mismatched! got %+v", s)
+	}
+	if s := allow.Stats()[0]; s.Rejected != 0 || s.Accepted != 1 {
+		t.Errorf("allow stats mismatched! got %+v", s)
+	}
+	if ln.Rules() != allow {
+		t.Errorf("rules not swapped")
+	}
+
+	ln.SetRules(nil)
+	if rs := ln.Rules(); rs == nil || rs.Policy() != Deny || len(rs.Rules()) != 0 {
+		t.Errorf("nil rules should deny every connection")
+	}
+}
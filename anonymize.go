@@ -0,0 +1,134 @@
+package ipaddr
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+)
+
+type Anonymizer interface {
+	Anonymize(IP) IP
+}
+
+type Truncation struct {
+	Zero4 int
+	Zero6 int
+}
+
+var DefaultTruncation = Truncation{
+	Zero4: netmask32 - netmask24,
+	Zero6: netmask128 - 48,
+}
+
+func (t Truncation) Anonymize(ip IP) IP {
+	n := t.Zero4
+	if ip.Is6() {
+		n = t.Zero6
+	}
+	bits := ip.bitLen()
+	switch {
+	case ip.zone == 0 || n <= 0:
+		return ip
+	case n > bits:
+		n = bits
+	}
+	nw, _ := ip.Mask(uint8(bits - n))
+	return nw.Address()
+}
+
+// CryptoPAn implements the prefix-preserving anonymization scheme of Xu, Fan,
+// Ammar and Moon: two addresses sharing a prefix of n bits are mapped to two
+// anonymized addresses sharing a prefix of n bits too.
+type CryptoPAn struct {
+	block cipher.Block
+	pad   bitset
+}
+
+func NewCryptoPAn(key []byte) (*CryptoPAn, error) {
+	if len(key) != 2*aes.BlockSize {
+		return nil, fmt.Errorf("crypto-pan: key must be %d bytes long (got %d)", 2*aes.BlockSize, len(key))
+	}
+	block, err := aes.NewCipher(key[:aes.BlockSize])
+	if err != nil {
+		return nil, err
+	}
+	var pad [aes.BlockSize]byte
+	block.Encrypt(pad[:], key[aes.BlockSize:])
+
+	c := CryptoPAn{
+		block: block,
+		pad:   fromBytes(pad[:]),
+	}
+	return &c, nil
+}
+
+func (c *CryptoPAn) Anonymize(ip IP) IP {
+	if ip.zone == 0 {
+		return ip
+	}
+	var (
+		orig = toBlock(ip)
+		otp  bitset
+	)
+	for i := 0; i < ip.bitLen(); i++ {
+		otp = otp.or(c.flip(orig, i))
+	}
+	return fromBlock(xor(orig, otp), ip.zone)
+}
+
+func (c *CryptoPAn) Deanonymize(ip IP) IP {
+	if ip.zone == 0 {
+		return ip
+	}
+	var (
+		anon = toBlock(ip)
+		orig bitset
+	)
+	for i := 0; i < ip.bitLen(); i++ {
+		bit := bitset{high: 1 << 63}.shr(uint(i))
+		orig = orig.or(xor(anon, c.flip(orig, i)).and(bit))
+	}
+	return fromBlock(orig, ip.zone)
+}
+
+func (c *CryptoPAn) flip(orig bitset, pos int) bitset {
+	var (
+		mask, _ = setbits(uint64(pos), netmask128)
+		input   = orig.and(mask).or(c.pad.and(mask.not()))
+		buf     [aes.BlockSize]byte
+	)
+	input.copy(buf[:])
+	c.block.Encrypt(buf[:], buf[:])
+	if buf[0]&0x80 == 0 {
+		return bitset{}
+	}
+	return bitset{high: 1 << 63}.shr(uint(pos))
+}
+
+func toBlock(ip IP) bitset {
+	if ip.zone == z4 {
+		return bitset{high: ip.set.low << 32}
+	}
+	return ip.set
+}
+
+func fromBlock(set bitset, z zone) IP {
+	if z == z4 {
+		set = bitset{low: set.high >> 32}
+	}
+	return makeIP(set, z)
+}
+
+func fromBytes(b []byte) bitset {
+	return bitset{
+		high: binary.BigEndian.Uint64(b),
+		low:  binary.BigEndian.Uint64(b[8:]),
+	}
+}
+
+func xor(a, b bitset) bitset {
+	a.high ^= b.high
+	a.low ^= b.low
+	return a
+}
@@ -0,0 +1,70 @@
+package ipaddr
+
+import (
+	"testing"
+)
+
+var cryptopanKey = []byte{
+	21, 34, 23, 141, 51, 164, 207, 128, 19, 10, 91, 22, 73, 144, 125, 16,
+	216, 152, 143, 131, 121, 121, 101, 39, 98, 87, 76, 45, 42, 132, 34, 2,
+}
+
+func TestCryptoPAn(t *testing.T) {
+	data := []struct {
+		Addr string
+		Want string
+	}{
+		{Addr: "128.11.68.132", Want: "135.242.180.132"},
+		{Addr: "129.118.74.4", Want: "134.136.186.123"},
+		{Addr: "130.132.252.244", Want: "133.68.164.234"},
+		{Addr: "141.223.7.43", Want: "141.167.8.160"},
+		{Addr: "141.233.145.108", Want: "141.129.237.235"},
+	}
+	pan, err := NewCryptoPAn(cryptopanKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, d := range data {
+		ip, _ := ParseIP(d.Addr)
+		got := pan.Anonymize(ip)
+		if got.String() != d.Want {
+			t.Errorf("%s: results mismatched! want %s, got %s", d.Addr, d.Want, got)
+		}
+		if back := pan.Deanonymize(got); back != ip {
+			t.Errorf("%s: deanonymized address mismatched! got %s", d.Addr, back)
+		}
+	}
+}
+
+func TestCryptoPAnPrefix(t *testing.T) {
+	pan, _ := NewCryptoPAn(cryptopanKey)
+	var (
+		a, _ = ParseIP("2001:db8:1:2::1")
+		b, _ = ParseIP("2001:db8:1:3::1")
+		x    = pan.Anonymize(a)
+		y    = pan.Anonymize(b)
+	)
+	if n := commonPrefix(x, y); n != commonPrefix(a, b) {
+		t.Errorf("prefix not preserved! want %d bits, got %d", commonPrefix(a, b), n)
+	}
+	if back := pan.Deanonymize(x); back != a {
+		t.Errorf("deanonymized address mismatched! want %s, got %s", a, back)
+	}
+}
+
+func TestTruncation(t *testing.T) {
+	data := []struct {
+		Addr string
+		Want string
+	}{
+		{Addr: "192.168.67.181", Want: "192.168.67.0"},
+		{Addr: "2001:db8:aaaa:dead:beef:cafe:0:1", Want: "2001:db8:aaaa::"},
+	}
+	for _, d := range data {
+		ip, _ := ParseIP(d.Addr)
+		got := DefaultTruncation.Anonymize(ip)
+		if got.String() != d.Want {
+			t.Errorf("%s: results mismatched! want %s, got %s", d.Addr, d.Want, got)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package cidr provides the CIDR functions of Terraform (cidrhost, cidrsubnet,
+// cidrsubnets and cidrnetmask) with the same results and error messages.
+package cidr
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/midbel/ipaddr"
+)
+
+const maxNewBits = 32
+
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"cidrhost":    Host,
+		"cidrsubnet":  Subnet,
+		"cidrsubnets": Subnets,
+		"cidrnetmask": Netmask,
+	}
+}
+
+func Host(prefix string, hostnum int64) (string, error) {
+	nw, err := parse(prefix)
+	if err != nil {
+		return "", err
+	}
+	ip, err := nw.Host(hostnum)
+	if err != nil {
+		return "", fmt.Errorf("prefix of %d does not accommodate a host numbered %d", nw.Size(), hostnum)
+	}
+	return ip.String(), nil
+}
+
+func Subnet(prefix string, newbits int, netnum int64) (string, error) {
+	nw, err := parse(prefix)
+	if err != nil {
+		return "", err
+	}
+	if newbits > maxNewBits {
+		return "", fmt.Errorf("may not extend prefix by more than %d bits", maxNewBits)
+	}
+	if newbits < 0 || nw.Size()+newbits > bitLen(nw) {
+		return "", fmt.Errorf("insufficient address space to extend prefix of %d by %d", nw.Size(), newbits)
+	}
+	if netnum < 0 {
+		return "", fmt.Errorf("prefix extension of %d does not accommodate a subnet numbered %d", newbits, netnum)
+	}
+	sub, err := nw.Subnet(newbits, uint64(netnum))
+	if err != nil {
+		return "", fmt.Errorf("prefix extension of %d does not accommodate a subnet numbered %d", newbits, netnum)
+	}
+	return sub.String(), nil
+}
+
+func Subnets(prefix string, newbits ...int) ([]string, error) {
+	nw, err := parse(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		list = make([]string, 0, len(newbits))
+		next = nw.Address()
+		curr = nw
+		done bool
+	)
+	for i, n := range newbits {
+		if n < 1 {
+			return nil, fmt.Errorf("argument %d: must extend prefix by at least one bit", i+1)
+		}
+		if n > maxNewBits {
+			return nil, fmt.Errorf("argument %d: may not extend prefix by more than %d bits", i+1, maxNewBits)
+		}
+		size := nw.Size() + n
+		if size > bitLen(nw) {
+			return nil, fmt.Errorf("argument %d: would extend prefix to %d bits, which is too long for an %s address", i+1, size, family(nw))
+		}
+		sub, err := align(next, size)
+		if done || err != nil || !nw.Contains(sub.Last()) {
+			return nil, fmt.Errorf("argument %d: not enough remaining address space for a subnet with a prefix of %d bits after %s", i+1, size, curr)
+		}
+		list = append(list, sub.String())
+		curr = sub
+		next, err = sub.Last().Next()
+		done = err != nil
+	}
+	return list, nil
+}
+
+func Netmask(prefix string) (string, error) {
+	nw, err := parse(prefix)
+	if err != nil {
+		return "", err
+	}
+	if nw.Address().Is6() {
+		return "", fmt.Errorf("IPv6 addresses cannot have a netmask: %s", prefix)
+	}
+	return nw.Netmask().String(), nil
+}
+
+func parse(prefix string) (ipaddr.Net, error) {
+	nw, err := ipaddr.ParseNet(prefix)
+	if err != nil {
+		return nw, fmt.Errorf("invalid CIDR expression: %s", err)
+	}
+	return nw, nil
+}
+
+func align(ip ipaddr.IP, size int) (ipaddr.Net, error) {
+	nw, err := ip.Mask(uint8(size))
+	if err != nil || nw.Address() == ip {
+		return nw, err
+	}
+	if ip, err = nw.Last().Next(); err != nil {
+		return nw, err
+	}
+	return ip.Mask(uint8(size))
+}
+
+func bitLen(nw ipaddr.Net) int {
+	if nw.Address().Is6() {
+		return 128
+	}
+	return 32
+}
+
+func family(nw ipaddr.Net) string {
+	if nw.Address().Is6() {
+		return "IPv6"
+	}
+	return "IPv4"
+}
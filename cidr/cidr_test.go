@@ -0,0 +1,150 @@
+package cidr
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestHost(t *testing.T) {
+	data := []struct {
+		Prefix string
+		Num    int64
+		Want   string
+		Err    bool
+	}{
+		{Prefix: "10.12.112.0/20", Num: 16, Want: "10.12.112.16"},
+		{Prefix: "10.12.112.0/20", Num: 268, Want: "10.12.113.12"},
+		{Prefix: "10.12.112.0/20", Num: -1, Want: "10.12.127.255"},
+		{Prefix: "10.12.112.0/20", Num: -4096, Want: "10.12.112.0"},
+		{Prefix: "fd00:fd12:3456:7890:00a2::/72", Num: 34, Want: "fd00:fd12:3456:7890::22"},
+		{Prefix: "10.12.112.0/20", Num: 4096, Err: true},
+		{Prefix: "10.12.112.0/20", Num: -4097, Err: true},
+	}
+	for _, d := range data {
+		got, err := Host(d.Prefix, d.Num)
+		if d.Err {
+			if err == nil {
+				t.Errorf("%s(%d): expected error, got %s", d.Prefix, d.Num, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s(%d): unexpected error %s", d.Prefix, d.Num, err)
+			continue
+		}
+		if got != d.Want {
+			t.Errorf("%s(%d): results mismatched! want %s, got %s", d.Prefix, d.Num, d.Want, got)
+		}
+	}
+}
+
+func TestSubnet(t *testing.T) {
+	data := []struct {
+		Prefix  string
+		Newbits int
+		Num     int64
+		Want    string
+		Err     string
+	}{
+		{Prefix: "172.16.0.0/12", Newbits: 4, Num: 2, Want: "172.18.0.0/16"},
+		{Prefix: "10.1.2.0/24", Newbits: 4, Num: 15, Want: "10.1.2.240/28"},
+		{Prefix: "fd00:fd12:3456:7890::/56", Newbits: 16, Num: 162, Want: "fd00:fd12:3456:7800:a200::/72"},
+		{Prefix: "10.1.2.0/24", Newbits: 4, Num: 16, Err: "prefix extension of 4 does not accommodate a subnet numbered 16"},
+		{Prefix: "10.1.2.0/24", Newbits: 9, Num: 1, Err: "insufficient address space to extend prefix of 24 by 9"},
+		{Prefix: "10.0.0.0/8", Newbits: 33, Num: 1, Err: "may not extend prefix by more than 32 bits"},
+		{Prefix: "10.0.0.0", Newbits: 1, Num: 1, Err: "invalid CIDR expression"},
+	}
+	for _, d := range data {
+		got, err := Subnet(d.Prefix, d.Newbits, d.Num)
+		if d.Err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), d.Err) {
+				t.Errorf("%s: errors mismatched! want %q, got %v", d.Prefix, d.Err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", d.Prefix, err)
+			continue
+		}
+		if got != d.Want {
+			t.Errorf("%s: results mismatched! want %s, got %s", d.Prefix, d.Want, got)
+		}
+	}
+}
+
+func TestSubnets(t *testing.T) {
+	data := []struct {
+		Prefix  string
+		Newbits []int
+		Want    []string
+		Err     bool
+	}{
+		{
+			Prefix:  "10.1.0.0/16",
+			Newbits: []int{4, 4, 8, 4},
+			Want:    []string{"10.1.0.0/20", "10.1.16.0/20", "10.1.32.0/24", "10.1.48.0/20"},
+		},
+		{
+			Prefix:  "fd00:fd12:3456:7890::/56",
+			Newbits: []int{16, 16, 16, 32},
+			Want: []string{
+				"fd00:fd12:3456:7800::/72",
+				"fd00:fd12:3456:7800:100::/72",
+				"fd00:fd12:3456:7800:200::/72",
+				"fd00:fd12:3456:7800:300::/88",
+			},
+		},
+		{
+			Prefix:  "10.1.0.0/24",
+			Newbits: []int{1, 1, 1},
+			Err:     true,
+		},
+		{
+			Prefix:  "10.1.0.0/24",
+			Newbits: []int{0},
+			Err:     true,
+		},
+	}
+	for _, d := range data {
+		got, err := Subnets(d.Prefix, d.Newbits...)
+		if d.Err {
+			if err == nil {
+				t.Errorf("%s: expected error, got %v", d.Prefix, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", d.Prefix, err)
+			continue
+		}
+		if strings.Join(got, ",") != strings.Join(d.Want, ",") {
+			t.Errorf("%s: results mismatched! want %v, got %v", d.Prefix, d.Want, got)
+		}
+	}
+}
+
+func TestNetmask(t *testing.T) {
+	got, err := Netmask("172.16.0.0/12")
+	if err != nil || got != "255.240.0.0" {
+		t.Errorf("results mismatched! want 255.240.0.0, got %s (%v)", got, err)
+	}
+	if _, err := Netmask("2001:db8::/32"); err == nil {
+		t.Errorf("IPv6 network should not have netmask")
+	}
+}
+
+func TestFuncMap(t *testing.T) {
+	const text = `{{cidrhost "10.12.112.0/20" 16}} {{cidrsubnet "172.16.0.0/12" 4 2}} {{cidrnetmask "10.0.0.0/8"}}`
+	tpl, err := template.New("cidr").Funcs(FuncMap()).Parse(text)
+	if err != nil {
+		t.Fatalf("fail to parse template: %s", err)
+	}
+	var str strings.Builder
+	if err := tpl.Execute(&str, nil); err != nil {
+		t.Fatalf("fail to execute template: %s", err)
+	}
+	if want := "10.12.112.16 172.18.0.0/16 255.0.0.0"; str.String() != want {
+		t.Errorf("results mismatched! want %s, got %s", want, str.String())
+	}
+}
@@ -0,0 +1,67 @@
+package ipaddr
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var ErrFamily = errors.New("mixed address families")
+
+type Cluster struct {
+	Net   Net
+	Count int
+}
+
+func (i IP) CommonPrefixLen(other IP) int {
+	return commonPrefix(i, other)
+}
+
+func CoveringNet(ips ...IP) (Net, error) {
+	if len(ips) == 0 {
+		return Net{}, fmt.Errorf("no address given: %w", ErrInvalid)
+	}
+	var (
+		first = ips[0]
+		size  = first.bitLen()
+	)
+	if first.zone == 0 {
+		return Net{}, ErrInvalid
+	}
+	for _, ip := range ips[1:] {
+		if ip.zone != first.zone {
+			return Net{}, fmt.Errorf("%s and %s: %w", first, ip, ErrFamily)
+		}
+		if n := commonPrefix(first, ip); n < size {
+			size = n
+		}
+	}
+	return first.Mask(uint8(size))
+}
+
+func Clusters(ips []IP, mask uint8) ([]Cluster, error) {
+	var (
+		list  []Cluster
+		index = make(map[Net]int)
+	)
+	for _, ip := range ips {
+		nw, err := ip.Mask(mask)
+		if err != nil {
+			return nil, fmt.Errorf("%s/%d: %w", ip, mask, err)
+		}
+		x, ok := index[nw]
+		if !ok {
+			x = len(list)
+			index[nw] = x
+			list = append(list, Cluster{Net: nw})
+		}
+		list[x].Count++
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Net.ip.Less(list[j].Net.ip)
+	})
+	return list, nil
+}
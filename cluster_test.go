@@ -0,0 +1,89 @@
+package ipaddr
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCoveringNet(t *testing.T) {
+	data := []struct {
+		Addrs []string
+		Want  string
+		Err   error
+	}{
+		{
+			Addrs: []string{"192.168.1.10", "192.168.1.200", "192.168.3.1"},
+			Want:  "192.168.0.0/22",
+		},
+		{
+			Addrs: []string{"10.0.0.1"},
+			Want:  "10.0.0.1/32",
+		},
+		{
+			Addrs: []string{"2001:db8::1", "2001:db8:ffff::1"},
+			Want:  "2001:db8::/32",
+		},
+		{
+			Addrs: []string{"10.0.0.1", "2001:db8::1"},
+			Err:   ErrFamily,
+		},
+		{
+			Err: ErrInvalid,
+		},
+	}
+	for _, d := range data {
+		nw, err := CoveringNet(parseList(t, d.Addrs)...)
+		if d.Err != nil {
+			if !errors.Is(err, d.Err) {
+				t.Errorf("%v: errors mismatched! want %s, got %v", d.Addrs, d.Err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error %s", d.Addrs, err)
+			continue
+		}
+		if nw.String() != d.Want {
+			t.Errorf("%v: results mismatched! want %s, got %s", d.Addrs, d.Want, nw)
+		}
+	}
+}
+
+func TestCommonPrefixLen(t *testing.T) {
+	a, _ := ParseIP("10.0.0.1")
+	b, _ := ParseIP("10.0.0.129")
+	if n := a.CommonPrefixLen(b); n != 24 {
+		t.Errorf("results mismatched! want 24, got %d", n)
+	}
+}
+
+func TestClusters(t *testing.T) {
+	ips := parseList(t, []string{
+		"10.0.1.1",
+		"10.0.2.1",
+		"10.0.1.2",
+		"10.0.3.1",
+		"10.0.1.3",
+		"10.0.2.2",
+	})
+	list, err := Clusters(ips, 24)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []struct {
+		Net   string
+		Count int
+	}{
+		{Net: "10.0.1.0/24", Count: 3},
+		{Net: "10.0.2.0/24", Count: 2},
+		{Net: "10.0.3.0/24", Count: 1},
+	}
+	if len(list) != len(want) {
+		t.Fatalf("length mismatched! want %d, got %d", len(want), len(list))
+	}
+	for i := range want {
+		if list[i].Net.String() != want[i].Net || list[i].Count != want[i].Count {
+			t.Errorf("%d: results mismatched! want %s (%d), got %s (%d)", i, want[i].Net, want[i].Count, list[i].Net, list[i].Count)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package ipaddr
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+type Match struct {
+	IP    IP
+	Net   Net
+	CIDR  bool
+	Start int
+	End   int
+}
+
+func Find(text []byte) []Match {
+	var list []Match
+	find(text, 0, func(m Match) {
+		list = append(list, m)
+	})
+	return list
+}
+
+func FindString(str string) []Match {
+	return Find([]byte(str))
+}
+
+func Replace(text []byte, fn func(Match) string) []byte {
+	var (
+		buf  bytes.Buffer
+		prev int
+	)
+	find(text, 0, func(m Match) {
+		buf.Write(text[prev:m.Start])
+		buf.WriteString(fn(m))
+		prev = m.End
+	})
+	buf.Write(text[prev:])
+	return buf.Bytes()
+}
+
+func ReplaceString(str string, fn func(Match) string) string {
+	return string(Replace([]byte(str), fn))
+}
+
+type Scanner struct {
+	reader  *bufio.Reader
+	offset  int
+	pending []Match
+	match   Match
+	err     error
+}
+
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{
+		reader: bufio.NewReader(r),
+	}
+}
+
+func (s *Scanner) Scan() bool {
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			return false
+		}
+		line, err := s.reader.ReadBytes('\n')
+		find(line, s.offset, func(m Match) {
+			s.pending = append(s.pending, m)
+		})
+		s.offset += len(line)
+		s.err = err
+	}
+	s.match, s.pending = s.pending[0], s.pending[1:]
+	return true
+}
+
+func (s *Scanner) Match() Match {
+	return s.match
+}
+
+func (s *Scanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+func find(text []byte, base int, fn func(Match)) {
+	for i := 0; i < len(text); {
+		if !isAddrChar(text[i]) && text[i] != '[' {
+			i++
+			continue
+		}
+		if i > 0 && (isWordChar(text[i-1]) || text[i-1] == dot || text[i-1] == colon) {
+			i = skipAddr(text, i)
+			continue
+		}
+		m, n, ok := matchAddr(text, i)
+		if !ok {
+			i = skipAddr(text, i)
+			continue
+		}
+		m.Start += base
+		m.End += base
+		fn(m)
+		i = n
+	}
+}
+
+func matchAddr(text []byte, i int) (Match, int, bool) {
+	var m Match
+	if text[i] == '[' {
+		j := skipAddr(text, i+1)
+		if j >= len(text) || text[j] != ']' {
+			return m, 0, false
+		}
+		ip, err := ParseIP(string(text[i+1 : j]))
+		if err != nil || !ip.Is6() {
+			return m, 0, false
+		}
+		m.IP, m.Start, m.End = ip, i+1, j
+		return m, j + 1, true
+	}
+	j := skipAddr(text, i)
+	if j < len(text) && isWordChar(text[j]) {
+		return m, 0, false
+	}
+	if j < len(text) && text[j] == '/' {
+		k := j + 1
+		for k < len(text) && isDigit(text[k]) {
+			k++
+		}
+		if k > j+1 && (k == len(text) || !isWordChar(text[k])) {
+			ip, nw, err := ParseCIDR(string(text[i:k]))
+			if err == nil {
+				m.IP, m.Net, m.CIDR = ip, nw, true
+				m.Start, m.End = i, k
+				return m, k, true
+			}
+		}
+	}
+	for str := text[i:j]; len(str) > 0; {
+		if ip, err := ParseIP(string(str)); err == nil {
+			m.IP, m.Start, m.End = ip, i, i+len(str)
+			return m, j, true
+		}
+		if x := bytes.IndexByte(str, colon); x > 0 && bytes.IndexByte(str[:x], dot) > 0 {
+			str = str[:x]
+			continue
+		}
+		if last := str[len(str)-1]; last != dot && last != colon {
+			break
+		}
+		str = str[:len(str)-1]
+	}
+	return m, 0, false
+}
+
+func skipAddr(text []byte, i int) int {
+	j := i
+	for j < len(text) && isAddrChar(text[j]) {
+		j++
+	}
+	if j == i {
+		j++
+	}
+	return j
+}
+
+func isAddrChar(c byte) bool {
+	return isHex(c) || c == dot || c == colon
+}
+
+func isWordChar(c byte) bool {
+	return isDigit(c) || c == '_' || (c|0x20 >= 'a' && c|0x20 <= 'z')
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'f')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
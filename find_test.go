@@ -0,0 +1,88 @@
+package ipaddr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindString(t *testing.T) {
+	data := []struct {
+		Text string
+		Want []string
+	}{
+		{
+			Text: "connection from 10.0.0.1 to 192.168.1.254.",
+			Want: []string{"10.0.0.1", "192.168.1.254"},
+		},
+		{
+			Text: "GET http://[2001:db8::1]:8080/index.html from 172.16.5.4:51234",
+			Want: []string{"2001:db8::1", "172.16.5.4"},
+		},
+		{
+			Text: "route 10.1.0.0/16 via fe80::1, gateway 10.1.0.1;",
+			Want: []string{"10.1.0.0/16", "fe80::1", "10.1.0.1"},
+		},
+		{
+			Text: "version v1.2.3.4 at 12:30:45 by a10.0.0.1 mac 00:1a:2b:3c:4d:5e (1.2.3.4)",
+			Want: []string{"1.2.3.4"},
+		},
+		{
+			Text: "bad 10.0.0.256 and 1.2.3.4.5 but 2001:db8::/32!",
+			Want: []string{"2001:db8::/32"},
+		},
+	}
+	for _, d := range data {
+		var got []string
+		for _, m := range FindString(d.Text) {
+			got = append(got, d.Text[m.Start:m.End])
+			if m.CIDR {
+				if m.Net.String() != d.Text[m.Start:m.End] {
+					t.Errorf("%s: network mismatched! got %s", d.Text[m.Start:m.End], m.Net)
+				}
+			} else if m.IP.String() != d.Text[m.Start:m.End] {
+				t.Errorf("%s: address mismatched! got %s", d.Text[m.Start:m.End], m.IP)
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(d.Want, ",") {
+			t.Errorf("%q: results mismatched! want %v, got %v", d.Text, d.Want, got)
+		}
+	}
+}
+
+func TestReplaceString(t *testing.T) {
+	const (
+		text = "from 10.0.0.1 to [2001:db8::1]:443 via 192.168.0.0/24"
+		want = "from x.x.x.x to [xxxx]:443 via 192.168.0.0/24"
+	)
+	got := ReplaceString(text, func(m Match) string {
+		switch {
+		case m.CIDR:
+			return m.Net.String()
+		case m.IP.Is4():
+			return "x.x.x.x"
+		default:
+			return "xxxx"
+		}
+	})
+	if got != want {
+		t.Errorf("results mismatched! want %s, got %s", want, got)
+	}
+}
+
+func TestScanner(t *testing.T) {
+	const text = "first 10.0.0.1\nsecond line\nthird ::1 and 10.0.0.2"
+	var (
+		scan = NewScanner(strings.NewReader(text))
+		got  []string
+	)
+	for scan.Scan() {
+		m := scan.Match()
+		got = append(got, text[m.Start:m.End])
+	}
+	if err := scan.Err(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "10.0.0.1,::1,10.0.0.2"; strings.Join(got, ",") != want {
+		t.Errorf("results mismatched! want %s, got %v", want, got)
+	}
+}
@@ -0,0 +1,153 @@
+package ipaddr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func (i IP) Format(f fmt.State, verb rune) {
+	var str string
+	switch verb {
+	case 's', 'v':
+		if verb == 'v' && f.Flag('#') {
+			str = i.goString()
+		} else {
+			str = i.String()
+		}
+	case 'q':
+		str = strconv.Quote(i.String())
+	case 'b':
+		str = string(appendBinary(nil, i.set, i.zone))
+	case 'x', 'X':
+		str = string(appendHex(nil, i.set, i.zone, f.Flag('#'), verb == 'X'))
+	default:
+		fmt.Fprintf(f, "%%!%c(ipaddr.IP=%s)", verb, i.String())
+		return
+	}
+	pad(f, str)
+}
+
+func (n Net) Format(f fmt.State, verb rune) {
+	var str string
+	switch verb {
+	case 's', 'v':
+		if verb == 'v' && f.Flag('#') {
+			str = fmt.Sprintf("ipaddr.MustParseNet(%q)", n.String())
+		} else {
+			str = n.String()
+		}
+	case 'q':
+		str = strconv.Quote(n.String())
+	case 'b':
+		buf := appendBinary(nil, n.ip.set, n.ip.zone)
+		buf = append(buf, '/')
+		str = string(appendBinary(buf, n.mask, n.ip.zone))
+	case 'x', 'X':
+		var (
+			sharp = f.Flag('#')
+			upper = verb == 'X'
+		)
+		buf := appendHex(nil, n.ip.set, n.ip.zone, sharp, upper)
+		buf = append(buf, '/')
+		str = string(appendHex(buf, n.mask, n.ip.zone, sharp, upper))
+	default:
+		fmt.Fprintf(f, "%%!%c(ipaddr.Net=%s)", verb, n.String())
+		return
+	}
+	pad(f, str)
+}
+
+func (i IP) goString() string {
+	var (
+		str  strings.Builder
+		size = 8
+		step = 16
+	)
+	switch i.zone {
+	case z4:
+		str.WriteString("ipaddr.IPv4(")
+		size, step = 4, 8
+	case z6:
+		str.WriteString("ipaddr.IPv6(")
+	default:
+		return "ipaddr.IP{}"
+	}
+	for j := size - 1; j >= 0; j-- {
+		v := i.set.shr(uint(j*step)).low & (1<<step - 1)
+		if i.zone == z6 && v > 9 {
+			str.WriteString("0x")
+			str.WriteString(strconv.FormatUint(v, 16))
+		} else {
+			str.WriteString(strconv.FormatUint(v, 10))
+		}
+		if j > 0 {
+			str.WriteString(", ")
+		}
+	}
+	str.WriteString(")")
+	if i.zid != "" {
+		fmt.Fprintf(&str, ".WithZone(%q)", i.zid)
+	}
+	return str.String()
+}
+
+func appendBinary(dst []byte, set bitset, z zone) []byte {
+	size, step, sep := 4, 8, byte(dot)
+	switch z {
+	case z6:
+		size, step, sep = 8, 16, colon
+	case 0:
+		return dst
+	}
+	for j := size - 1; j >= 0; j-- {
+		v := set.shr(uint(j*step)).low & (1<<step - 1)
+		for k := step - 1; k >= 0; k-- {
+			dst = append(dst, '0'+byte(v>>k&1))
+		}
+		if j > 0 {
+			dst = append(dst, sep)
+		}
+	}
+	return dst
+}
+
+func appendHex(dst []byte, set bitset, z zone, sharp, upper bool) []byte {
+	digits := "0123456789abcdef"
+	if upper {
+		digits = "0123456789ABCDEF"
+	}
+	size := 8
+	switch z {
+	case z6:
+		size = 32
+	case 0:
+		return dst
+	}
+	if sharp {
+		if upper {
+			dst = append(dst, "0X"...)
+		} else {
+			dst = append(dst, "0x"...)
+		}
+	}
+	for j := size - 1; j >= 0; j-- {
+		dst = append(dst, digits[set.shr(uint(j*4)).low&0xf])
+	}
+	return dst
+}
+
+func pad(f fmt.State, str string) {
+	width, ok := f.Width()
+	if !ok || width <= len(str) {
+		f.Write([]byte(str))
+		return
+	}
+	fill := strings.Repeat(" ", width-len(str))
+	if f.Flag('-') {
+		str += fill
+	} else {
+		str = fill + str
+	}
+	f.Write([]byte(str))
+}
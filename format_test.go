@@ -0,0 +1,57 @@
+package ipaddr
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFormatIP(t *testing.T) {
+	var (
+		ip4 = IPv4(10, 0, 0, 1)
+		ip6 = IPv6(0x2001, 0xdb8, 0, 0, 0, 0, 0, 1)
+	)
+	data := []struct {
+		Format string
+		Value  interface{}
+		Want   string
+	}{
+		{Format: "%s", Value: ip4, Want: "10.0.0.1"},
+		{Format: "%v", Value: ip6, Want: "2001:db8::1"},
+		{Format: "%q", Value: ip4, Want: `"10.0.0.1"`},
+		{Format: "%b", Value: ip4, Want: "00001010.00000000.00000000.00000001"},
+		{Format: "%x", Value: ip4, Want: "0a000001"},
+		{Format: "%#X", Value: ip4, Want: "0X0A000001"},
+		{Format: "%x", Value: ip6, Want: "20010db8000000000000000000000001"},
+		{Format: "%#v", Value: ip4, Want: "ipaddr.IPv4(10, 0, 0, 1)"},
+		{Format: "%#v", Value: ip6, Want: "ipaddr.IPv6(0x2001, 0xdb8, 0, 0, 0, 0, 0, 1)"},
+		{Format: "%12s|", Value: ip4, Want: "    10.0.0.1|"},
+		{Format: "%-12s|", Value: ip4, Want: "10.0.0.1    |"},
+		{Format: "%d", Value: ip4, Want: "%!d(ipaddr.IP=10.0.0.1)"},
+	}
+	for _, d := range data {
+		got := fmt.Sprintf(d.Format, d.Value)
+		if got != d.Want {
+			t.Errorf("%s: results mismatched! want %s, got %s", d.Format, d.Want, got)
+		}
+	}
+}
+
+func TestFormatNet(t *testing.T) {
+	nw, _ := ParseNet("192.168.1.0/24")
+	data := []struct {
+		Format string
+		Want   string
+	}{
+		{Format: "%s", Want: "192.168.1.0/24"},
+		{Format: "%b", Want: "11000000.10101000.00000001.00000000/11111111.11111111.11111111.00000000"},
+		{Format: "%x", Want: "c0a80100/ffffff00"},
+		{Format: "%#v", Want: `ipaddr.MustParseNet("192.168.1.0/24")`},
+		{Format: "%-16v|", Want: "192.168.1.0/24  |"},
+	}
+	for _, d := range data {
+		got := fmt.Sprintf(d.Format, nw)
+		if got != d.Want {
+			t.Errorf("%s: results mismatched! want %s, got %s", d.Format, d.Want, got)
+		}
+	}
+}
@@ -7,11 +7,46 @@ import (
 	"math"
 	"math/bits"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
+	"unsafe"
 )
 
-var ErrInvalid = errors.New("invalid IP address")
+var (
+	ErrInvalid = errors.New("invalid IP address")
+	ErrRange   = errors.New("address out of range")
+)
+
+type ParseError struct {
+	Input  string
+	Offset int
+	Reason string
+}
+
+func parseError(str string, offset int, reason string) error {
+	return &ParseError{
+		Input:  str,
+		Offset: offset,
+		Reason: reason,
+	}
+}
+
+func withInput(err error, str string) error {
+	var e *ParseError
+	if errors.As(err, &e) {
+		e.Input = str
+	}
+	return err
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s %q: %s at offset %d", ErrInvalid, e.Input, e.Reason, e.Offset)
+}
+
+func (e *ParseError) Unwrap() error {
+	return ErrInvalid
+}
 
 const (
 	netmask4   = 4
@@ -77,43 +112,57 @@ func (z zone) String() string {
 }
 
 type IP struct {
-	set  bitset
-	mask uint8
+	set bitset
 	zone
+	zid string
 }
 
 var Zero IP
 
 func ParseIP(str string) (IP, error) {
-	if strings.Index(str, ".") > 0 {
-		return parseIPv4(str)
-	}
 	if strings.Index(str, ":") >= 0 {
+		var zid string
+		if x := strings.Index(str, "%"); x >= 0 {
+			if x == len(str)-1 {
+				return Zero, parseError(str, x, "empty zone")
+			}
+			zid = str[x+1:]
+			ip, err := parseIPv6(str[:x])
+			if err != nil {
+				return Zero, withInput(err, str)
+			}
+			return ip.WithZone(zid), nil
+		}
 		return parseIPv6(str)
 	}
-	return Zero, ErrInvalid
+	if strings.Index(str, ".") >= 0 {
+		return parseIPv4(str)
+	}
+	if str == "" {
+		return Zero, parseError(str, 0, "empty input")
+	}
+	return Zero, parseError(str, 0, "missing separator")
 }
 
-func ParseCIDR(str string) (IP, Net, error) {
-	var (
-		x   = strings.Index(str, "/")
-		ip  IP
-		nw  Net
-		err error
-	)
-	if x <= 0 {
-		return ip, nw, ErrInvalid
+func ParseIPBytes(b []byte) (IP, error) {
+	// the parser only reads its input: it is safe to give it a view of b as
+	// long as nothing referencing it outlives this call.
+	ip, err := ParseIP(*(*string)(unsafe.Pointer(&b)))
+	if err != nil {
+		return Zero, withInput(err, string(b))
 	}
-	if ip, err = ParseIP(str[:x]); err != nil {
-		return ip, nw, err
+	if ip.zid != "" {
+		ip.zid = string(b[len(b)-len(ip.zid):])
 	}
-	mask, err := strconv.ParseUint(str[x+1:], 10, 8)
+	return ip, nil
+}
+
+func ParseCIDR(str string) (IP, Net, error) {
+	a, err := ParseIfAddr(str)
 	if err != nil {
-		return ip, nw, ErrInvalid
+		return Zero, Net{}, err
 	}
-	ip.mask = uint8(mask)
-	nw, err = ip.Mask(ip.mask)
-	return ip, nw, err
+	return a.ip, a.Net(), nil
 }
 
 func FromStdIP(ip net.IP) (IP, error) {
@@ -148,22 +197,45 @@ func IPv6(a, b, c, d, e, f, g, h uint16) IP {
 }
 
 func (i IP) String() string {
-	if i.zone == 0 {
-		return ""
-	}
-	if i.zone == z4 {
-		return formatIPv4(i)
+	var buf [64]byte
+	return string(i.AppendTo(buf[:0]))
+}
+
+func (i IP) AppendTo(dst []byte) []byte {
+	switch i.zone {
+	case z4:
+		return appendIPv4(dst, i)
+	case z6:
+		dst = appendIPv6(dst, i)
+		if i.zid != "" {
+			dst = append(dst, '%')
+			dst = append(dst, i.zid...)
+		}
+		return dst
+	default:
+		return dst
 	}
-	return formatIPv6(i)
 }
 
 func (i IP) Equal(other IP) bool {
-	return i.zone == other.zone && i.set.equal(other.set)
+	return i.zone == other.zone && i.zid == other.zid && i.set.equal(other.set)
+}
+
+func (i IP) Zone() string {
+	return i.zid
+}
+
+func (i IP) WithZone(zid string) IP {
+	if i.zone != z6 {
+		return i
+	}
+	i.zid = zid
+	return i
 }
 
 func (i IP) Less(other IP) bool {
-	if i.zone < other.zone {
-		return true
+	if i.zone != other.zone {
+		return i.zone < other.zone
 	}
 	return i.set.less(other.set)
 }
@@ -187,9 +259,21 @@ func (i IP) To6() IP {
 	if i.Is6() {
 		return i
 	}
+	if !i.Is4() {
+		return Zero
+	}
 	i.zone = z6
 	i.set.low |= 0xffff << 32
-	return Zero
+	return i
+}
+
+// Unmap returns the IPv4 address embedded in an IPv4-mapped IPv6 address.
+// Other addresses are returned unchanged.
+func (i IP) Unmap() IP {
+	if i.zone != z6 || i.set.high != 0 || i.set.low>>32 != 0xffff {
+		return i
+	}
+	return makeIP(bitset{low: i.set.low & math.MaxUint32}, z4)
 }
 
 func (i IP) Mask(mask uint8) (Net, error) {
@@ -215,11 +299,54 @@ func (i IP) Net() (Net, error) {
 		}
 		return n, nil
 	}
-	m := i.mask
-	if m == 0 {
-		m = uint8(i.DefaultMask())
+	return i.Mask(uint8(i.DefaultMask()))
+}
+
+func (i IP) IfAddr(mask uint8) (IfAddr, error) {
+	if int(mask) > i.bitLen() {
+		return IfAddr{}, ErrInvalid
+	}
+	a := IfAddr{
+		ip:   i,
+		mask: mask,
+	}
+	return a, nil
+}
+
+func (i IP) Next() (IP, error) {
+	if i.zone == 0 {
+		return Zero, ErrInvalid
 	}
-	return i.Mask(m)
+	set, carry := i.set.add(bitset{low: 1})
+	if carry || !set.and(i.limit().not()).isZero() {
+		return Zero, fmt.Errorf("%s: %w", i, ErrRange)
+	}
+	return makeIP(set, i.zone), nil
+}
+
+func (i IP) Prev() (IP, error) {
+	if i.zone == 0 {
+		return Zero, ErrInvalid
+	}
+	set, borrow := i.set.sub(bitset{low: 1})
+	if borrow {
+		return Zero, fmt.Errorf("%s: %w", i, ErrRange)
+	}
+	return makeIP(set, i.zone), nil
+}
+
+func (i IP) limit() bitset {
+	if i.zone == z6 {
+		return bitset{high: math.MaxUint64, low: math.MaxUint64}
+	}
+	return bitset{low: math.MaxUint32}
+}
+
+func (i IP) bitLen() int {
+	if i.zone == z6 {
+		return netmask128
+	}
+	return netmask32
 }
 
 func (i IP) DefaultMask() int {
@@ -248,7 +375,7 @@ func (i IP) IsMulticast() bool {
 		return false
 	}
 	if i.zone == z4 {
-		return byte(i.set.low>>4) == 0b1110
+		return byte(i.set.low>>28) == 0b1110
 	}
 	return byte(i.set.high>>56) == 0b1111_1111
 }
@@ -311,9 +438,69 @@ func (i IP) Class() Class {
 }
 
 func (i IP) ToStdIP() net.IP {
-	ip := make(net.IP, net.IPv6len)
-	i.set.copy(ip)
-	return ip
+	switch i.zone {
+	case z4:
+		v := i.set.low
+		return net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v)).To4()
+	case z6:
+		ip := make(net.IP, net.IPv6len)
+		i.set.copy(ip)
+		return ip
+	default:
+		return nil
+	}
+}
+
+type IfAddr struct {
+	ip   IP
+	mask uint8
+}
+
+func ParseIfAddr(str string) (IfAddr, error) {
+	x := strings.Index(str, "/")
+	if x < 0 {
+		return IfAddr{}, parseError(str, len(str), "missing prefix length")
+	}
+	ip, err := ParseIP(str[:x])
+	if err != nil {
+		return IfAddr{}, withInput(err, str)
+	}
+	mask, err := parsePrefixLen(str, x+1, ip.bitLen())
+	if err != nil {
+		return IfAddr{}, err
+	}
+	return ip.IfAddr(mask)
+}
+
+func (a IfAddr) IP() IP {
+	return a.ip
+}
+
+func (a IfAddr) Len() int {
+	return int(a.mask)
+}
+
+func (a IfAddr) Net() Net {
+	n, _ := a.ip.Mask(a.mask)
+	return n
+}
+
+func (a IfAddr) Equal(other IfAddr) bool {
+	return a.mask == other.mask && a.ip.Equal(other.ip)
+}
+
+func (a IfAddr) String() string {
+	var buf [64]byte
+	return string(a.AppendTo(buf[:0]))
+}
+
+func (a IfAddr) AppendTo(dst []byte) []byte {
+	if a.ip.zone == 0 {
+		return dst
+	}
+	dst = a.ip.AppendTo(dst)
+	dst = append(dst, '/')
+	return strconv.AppendUint(dst, uint64(a.mask), 10)
 }
 
 type Net struct {
@@ -326,6 +513,44 @@ func ParseNet(str string) (Net, error) {
 	return nw, err
 }
 
+// MustParseNet is like ParseNet but panics if str is not a valid network. It
+// simplifies the initialization of variables and is used by the %#v verb.
+func MustParseNet(str string) Net {
+	nw, err := ParseNet(str)
+	if err != nil {
+		panic(err)
+	}
+	return nw
+}
+
+func FromStdNet(nw *net.IPNet) (Net, error) {
+	if nw == nil {
+		return Net{}, ErrInvalid
+	}
+	ones, bits := nw.Mask.Size()
+	if bits == 0 {
+		return Net{}, fmt.Errorf("non canonical netmask %s: %w", nw.Mask, ErrInvalid)
+	}
+	ip, err := FromStdIP(nw.IP)
+	if err != nil {
+		return Net{}, err
+	}
+	if ip.Is4() && bits == netmask128 {
+		ones -= netmask128 - netmask32
+	}
+	if ones < 0 || (ip.Is6() && bits != netmask128) {
+		return Net{}, fmt.Errorf("%s: mask mismatched with address family: %w", nw, ErrInvalid)
+	}
+	return ip.Mask(uint8(ones))
+}
+
+func (n Net) ToStdNet() *net.IPNet {
+	return &net.IPNet{
+		IP:   n.ip.ToStdIP(),
+		Mask: net.CIDRMask(n.mask.ones(), n.ip.bitLen()),
+	}
+}
+
 func (n Net) Contains(ip IP) bool {
 	set := n.mask.and(ip.set)
 	return set.equal(n.ip.set)
@@ -365,6 +590,162 @@ func (n Net) Broadcast() IP {
 	return n.ip
 }
 
+func (n Net) Last() IP {
+	host := n.mask.not().and(n.ip.limit())
+	return makeIP(n.ip.set.or(host), n.ip.zone)
+}
+
+func (n Net) Host(num int64) (IP, error) {
+	var (
+		host = n.mask.not().and(n.ip.limit())
+		off  bitset
+	)
+	if num >= 0 {
+		off.low = uint64(num)
+	} else {
+		var borrow bool
+		off, borrow = host.sub(bitset{low: uint64(-(num + 1))})
+		if borrow {
+			return Zero, fmt.Errorf("%s: host %d: %w", n, num, ErrRange)
+		}
+	}
+	if host.less(off) {
+		return Zero, fmt.Errorf("%s: host %d: %w", n, num, ErrRange)
+	}
+	return makeIP(n.ip.set.or(off), n.ip.zone), nil
+}
+
+func (n Net) Subnet(newbits int, num uint64) (Net, error) {
+	var (
+		size = n.mask.ones() + newbits
+		bits = n.ip.bitLen()
+	)
+	if newbits < 0 || size > bits {
+		return Net{}, fmt.Errorf("%s: can not extend prefix by %d bits: %w", n, newbits, ErrRange)
+	}
+	if newbits < netmask64 && num >= 1<<newbits {
+		return Net{}, fmt.Errorf("%s: subnet %d: %w", n, num, ErrRange)
+	}
+	mask, _ := setbits(uint64(size), uint64(bits))
+	off := bitset{low: num}.shl(uint(bits - size))
+	x := Net{
+		ip:   makeIP(n.ip.set.or(off), n.ip.zone),
+		mask: mask,
+	}
+	return x, nil
+}
+
+func (n Net) Exclude(other Net) []Net {
+	if !n.covers(other) {
+		if other.covers(n) {
+			return nil
+		}
+		return []Net{n}
+	}
+	var list []Net
+	for curr := n; !curr.Equal(other); {
+		lo, hi := curr.halves()
+		if lo.covers(other) {
+			list, curr = append(list, hi), lo
+		} else {
+			list, curr = append(list, lo), hi
+		}
+	}
+	sortNets(list)
+	return list
+}
+
+func (n Net) ExcludeAll(others ...Net) []Net {
+	list := []Net{n}
+	for _, o := range others {
+		var tmp []Net
+		for _, x := range list {
+			tmp = append(tmp, x.Exclude(o)...)
+		}
+		list = tmp
+	}
+	sortNets(list)
+	return list
+}
+
+func Collapse(nets ...Net) []Net {
+	list := make([]Net, len(nets))
+	copy(list, nets)
+	sortNets(list)
+
+	var res []Net
+	for _, n := range list {
+		if x := len(res); x > 0 && res[x-1].covers(n) {
+			continue
+		}
+		res = append(res, n)
+		for x := len(res); x >= 2; x = len(res) {
+			prev, curr := res[x-2], res[x-1]
+			if prev.ip.zone != curr.ip.zone || prev.mask.ones() != curr.mask.ones() || prev.mask.ones() == 0 {
+				break
+			}
+			parent, _ := prev.Parent()
+			if !parent.covers(curr) {
+				break
+			}
+			res = append(res[:x-2], parent)
+		}
+	}
+	return res
+}
+
+func (n Net) Next() (Net, error) {
+	ip, err := n.Last().Next()
+	if err != nil {
+		return Net{}, fmt.Errorf("%s: no next network: %w", n, ErrRange)
+	}
+	return ip.Mask(uint8(n.mask.ones()))
+}
+
+func (n Net) Prev() (Net, error) {
+	ip, err := n.ip.Prev()
+	if err != nil {
+		return Net{}, fmt.Errorf("%s: no previous network: %w", n, ErrRange)
+	}
+	return ip.Mask(uint8(n.mask.ones()))
+}
+
+func (n Net) Parent() (Net, error) {
+	size := n.mask.ones()
+	if size == 0 {
+		return Net{}, fmt.Errorf("%s: no parent network: %w", n, ErrRange)
+	}
+	return n.ip.Mask(uint8(size - 1))
+}
+
+func (n Net) Children() (Net, Net, error) {
+	if n.mask.ones() >= n.ip.bitLen() {
+		return Net{}, Net{}, fmt.Errorf("%s: no child networks: %w", n, ErrRange)
+	}
+	lo, hi := n.halves()
+	return lo, hi, nil
+}
+
+func (n Net) Supernet(size int) (Net, error) {
+	if size < 0 || size > n.mask.ones() {
+		return Net{}, fmt.Errorf("%s: invalid supernet length %d: %w", n, size, ErrRange)
+	}
+	return n.ip.Mask(uint8(size))
+}
+
+func (n Net) covers(other Net) bool {
+	if n.ip.zone != other.ip.zone || n.mask.ones() > other.mask.ones() {
+		return false
+	}
+	return n.Contains(other.ip)
+}
+
+func (n Net) halves() (Net, Net) {
+	lo, _ := n.Subnet(1, 0)
+	hi, _ := n.Subnet(1, 1)
+	return lo, hi
+}
+
 func (n Net) Address() IP {
 	return n.ip
 }
@@ -374,7 +755,23 @@ func (n Net) Netmask() IP {
 }
 
 func (n Net) String() string {
-	return fmt.Sprintf("%s/%d", n.ip, n.mask.ones())
+	var buf [64]byte
+	return string(n.AppendTo(buf[:0]))
+}
+
+func (n Net) AppendTo(dst []byte) []byte {
+	dst = n.ip.AppendTo(dst)
+	dst = append(dst, '/')
+	return strconv.AppendUint(dst, uint64(n.mask.ones()), 10)
+}
+
+func sortNets(list []Net) {
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].ip.Equal(list[j].ip) {
+			return list[i].ip.Less(list[j].ip)
+		}
+		return list[i].mask.ones() < list[j].mask.ones()
+	})
 }
 
 type bitset struct {
@@ -443,8 +840,8 @@ func (b bitset) equal(other bitset) bool {
 }
 
 func (b bitset) less(other bitset) bool {
-	if b.high < other.high {
-		return true
+	if b.high != other.high {
+		return b.high < other.high
 	}
 	return b.low < other.low
 }
@@ -464,6 +861,43 @@ func (b bitset) and(other bitset) bitset {
 	return other
 }
 
+func (b bitset) or(other bitset) bitset {
+	other.high |= b.high
+	other.low |= b.low
+	return other
+}
+
+func (b bitset) not() bitset {
+	b.high, b.low = ^b.high, ^b.low
+	return b
+}
+
+func (b bitset) add(other bitset) (bitset, bool) {
+	var carry uint64
+	b.low, carry = bits.Add64(b.low, other.low, 0)
+	b.high, carry = bits.Add64(b.high, other.high, carry)
+	return b, carry != 0
+}
+
+func (b bitset) sub(other bitset) (bitset, bool) {
+	var borrow uint64
+	b.low, borrow = bits.Sub64(b.low, other.low, 0)
+	b.high, borrow = bits.Sub64(b.high, other.high, borrow)
+	return b, borrow != 0
+}
+
+func (b bitset) shl(n uint) bitset {
+	switch {
+	case n >= netmask128:
+		return bitset{}
+	case n >= netmask64:
+		b.high, b.low = b.low<<(n-netmask64), 0
+	case n > 0:
+		b.high, b.low = b.high<<n|b.low>>(netmask64-n), b.low<<n
+	}
+	return b
+}
+
 func (b bitset) zeros() int {
 	var z int
 	for _, set := range []uint64{b.low, b.high} {
@@ -479,61 +913,173 @@ func (b bitset) zeros() int {
 	return z
 }
 
+func (b bitset) shr(n uint) bitset {
+	switch {
+	case n >= netmask128:
+		return bitset{}
+	case n >= netmask64:
+		b.high, b.low = 0, b.high>>(n-netmask64)
+	case n > 0:
+		b.high, b.low = b.high>>n, b.low>>n|b.high<<(netmask64-n)
+	}
+	return b
+}
+
+func (b bitset) len() int {
+	if b.high != 0 {
+		return netmask128 - bits.LeadingZeros64(b.high)
+	}
+	return netmask64 - bits.LeadingZeros64(b.low)
+}
+
 func (b bitset) ones() int {
 	return bits.OnesCount64(b.high) + bits.OnesCount64(b.low)
 }
 
 func parseIPv4(str string) (IP, error) {
+	return parseIPv4At(str, 0)
+}
+
+func parseIPv4At(str string, i int) (IP, error) {
 	var (
-		ip    = make([]uint8, net.IPv4len)
-		parts = strings.Split(str, ".")
+		parts [net.IPv4len]uint8
+		n     int
 	)
-	if len(parts) != net.IPv4len {
-		return Zero, ErrInvalid
-	}
-	for i := 0; i < len(parts); i++ {
-		b, err := strconv.ParseUint(parts[i], 10, 8)
-		if err != nil {
-			return Zero, ErrInvalid
+	for {
+		if n == len(parts) {
+			return Zero, parseError(str, i, "too many octets")
 		}
-		ip[i] = uint8(b)
+		var (
+			beg = i
+			val int
+		)
+		for i < len(str) && isDigit(str[i]) {
+			val = val*10 + int(str[i]-'0')
+			if val > 255 {
+				return Zero, parseError(str, beg, "octet out of range")
+			}
+			i++
+		}
+		if i == beg {
+			if i < len(str) && str[i] != dot {
+				return Zero, parseError(str, i, fmt.Sprintf("unexpected character %q", str[i]))
+			}
+			return Zero, parseError(str, i, "empty octet")
+		}
+		parts[n] = uint8(val)
+		n++
+		if i == len(str) {
+			break
+		}
+		if str[i] != dot {
+			return Zero, parseError(str, i, fmt.Sprintf("unexpected character %q", str[i]))
+		}
+		i++
 	}
-	return IPv4(ip[0], ip[1], ip[2], ip[3]), nil
+	if n < len(parts) {
+		return Zero, parseError(str, i, "too few octets")
+	}
+	return IPv4(parts[0], parts[1], parts[2], parts[3]), nil
 }
 
 func parseIPv6(str string) (IP, error) {
-	if str == "::" {
-		return Zero, nil
-	}
 	var (
-		ellipsis bool
-		ip       = make([]uint16, net.IPv6len/2)
-		parts    = strings.Split(str, ":")
+		groups   [net.IPv6len / 2]uint16
+		n        int
+		ellipsis = -1
+		i        int
 	)
-	if len(parts) > len(ip) || len(parts) <= 2 {
-		return Zero, ErrInvalid
+	if strings.HasPrefix(str, "::") {
+		ellipsis, i = 0, 2
+	} else if strings.HasPrefix(str, ":") {
+		return Zero, parseError(str, 0, "leading single colon")
 	}
-	var j int
-	for i := 0; i < len(parts); i++ {
-		if parts[i] == "" {
-			if ellipsis && i < len(parts)-1 {
-				return Zero, ErrInvalid
+	for i < len(str) {
+		if n == len(groups) {
+			return Zero, parseError(str, i, "too many groups")
+		}
+		var (
+			beg = i
+			val uint16
+		)
+		for i < len(str) && isHex(str[i]) {
+			if i-beg >= 4 {
+				return Zero, parseError(str, beg, "group exceeds 4 hex digits")
 			}
-			ellipsis = i > 0
-			j = len(ip) - (len(parts) - i) + 1
-			continue
+			val = val<<4 | hexValue(str[i])
+			i++
 		}
-		b, err := strconv.ParseUint(parts[i], 16, 16)
-		if err != nil {
-			return Zero, ErrInvalid
+		if i < len(str) && str[i] == dot {
+			if n > len(groups)-2 {
+				return Zero, parseError(str, beg, "too many groups")
+			}
+			ip, err := parseIPv4At(str, beg)
+			if err != nil {
+				return Zero, err
+			}
+			groups[n] = uint16(ip.set.low >> 16)
+			groups[n+1] = uint16(ip.set.low)
+			n += 2
+			break
+		}
+		if i == beg {
+			return Zero, parseError(str, i, fmt.Sprintf("unexpected character %q", str[i]))
+		}
+		groups[n] = val
+		n++
+		if i == len(str) {
+			break
+		}
+		if str[i] != colon {
+			return Zero, parseError(str, i, fmt.Sprintf("unexpected character %q", str[i]))
+		}
+		i++
+		if i < len(str) && str[i] == colon {
+			if ellipsis >= 0 {
+				return Zero, parseError(str, i-1, "multiple ::")
+			}
+			ellipsis = n
+			i++
+		} else if i == len(str) {
+			return Zero, parseError(str, i-1, "trailing single colon")
 		}
-		ip[j] = uint16(b)
-		j++
 	}
-	if j < len(ip) {
-		return Zero, ErrInvalid
+	switch {
+	case ellipsis < 0 && n < len(groups):
+		return Zero, parseError(str, len(str), "too few groups")
+	case ellipsis >= 0 && n == len(groups):
+		return Zero, parseError(str, ellipsis, "too many groups")
+	case ellipsis >= 0:
+		diff := len(groups) - n
+		copy(groups[ellipsis+diff:], groups[ellipsis:n])
+		for j := ellipsis; j < ellipsis+diff; j++ {
+			groups[j] = 0
+		}
+	}
+	return IPv6(groups[0], groups[1], groups[2], groups[3], groups[4], groups[5], groups[6], groups[7]), nil
+}
+
+func parsePrefixLen(str string, i, limit int) (uint8, error) {
+	if i == len(str) {
+		return 0, parseError(str, i, "missing prefix length")
 	}
-	return IPv6(ip[0], ip[1], ip[2], ip[3], ip[4], ip[5], ip[6], ip[7]), nil
+	var n int
+	for j := i; j < len(str); j++ {
+		if !isDigit(str[j]) {
+			return 0, parseError(str, j, fmt.Sprintf("unexpected character %q", str[j]))
+		}
+		if n = n*10 + int(str[j]-'0'); n > limit {
+			return 0, parseError(str, i, fmt.Sprintf("prefix length exceeds %d", limit))
+		}
+	}
+	return uint8(n), nil
+}
+
+func hexValue(c byte) uint16 {
+	if isDigit(c) {
+		return uint16(c - '0')
+	}
+	return uint16(c|0x20-'a') + 10
 }
 
 func makeIP(set bitset, z zone) IP {
@@ -548,8 +1094,7 @@ const (
 	dot   = '.'
 )
 
-func formatIPv4(ip IP) string {
-	str := make([]byte, 0, 4)
+func appendIPv4(str []byte, ip IP) []byte {
 	for i := 24; i >= 0; i -= 8 {
 		b := (ip.set.low >> i) & 0xFF
 		str = strconv.AppendUint(str, b, 10)
@@ -557,60 +1102,45 @@ func formatIPv4(ip IP) string {
 			str = append(str, dot)
 		}
 	}
-	return string(str)
+	return str
 }
 
-func formatIPv6(ip IP) string {
-	if ip.IsUndefined() {
-		return "::"
-	}
-	type ptr struct {
-		run int
-		beg int
-		end int
-	}
+func appendIPv6(str []byte, ip IP) []byte {
 	var (
-		str  = make([]byte, 0, 8)
-		curr ptr
-		prev ptr
+		groups [8]uint16
+		beg    = -1
+		size   int
 	)
-	for _, i := range []uint64{ip.set.high, ip.set.low} {
-		for j := 48; j >= 0; j -= 16 {
-			b := (i >> j) & 0xFFFF
-			if prev.run == 0 && b == 0 {
-				prev.beg = len(str)
-			}
-			if prev.run >= 0 && b == 0 {
-				prev.run++
-			}
-			if prev.run > 0 && b != 0 {
-				prev.end = len(str)
-				if prev.run >= curr.run {
-					curr, prev = prev, ptr{}
-				}
-			}
-			str = strconv.AppendUint(str, b, 16)
-			if j > 0 {
-				str = append(str, colon)
-			}
+	for i := 0; i < 4; i++ {
+		groups[i] = uint16(ip.set.high >> (48 - 16*i))
+		groups[i+4] = uint16(ip.set.low >> (48 - 16*i))
+	}
+	for i := 0; i < len(groups); {
+		if groups[i] != 0 {
+			i++
+			continue
 		}
-		if i == ip.set.high {
-			str = append(str, colon)
+		j := i
+		for j < len(groups) && groups[j] == 0 {
+			j++
 		}
-	}
-	if curr.run > 0 || prev.run > 0 {
-		if curr.run == 0 {
-			curr = prev
+		if n := j - i; n > 1 && n > size {
+			beg, size = i, n
 		}
-		if curr.end == 0 {
-			curr.end = len(str)
+		i = j
+	}
+	for i := 0; i < len(groups); i++ {
+		if i == beg {
+			str = append(str, colon, colon)
+			i += size - 1
+			continue
 		}
-		str = append(str[:curr.beg], append([]byte{colon}, str[curr.end:]...)...)
-		if curr.beg == 0 {
-			str = append([]byte{colon}, str...)
+		if i > 0 && i != beg+size {
+			str = append(str, colon)
 		}
+		str = strconv.AppendUint(str, uint64(groups[i]), 16)
 	}
-	return string(str)
+	return str
 }
 
 func defaultNetmaskIPv6(ip IP) int {
@@ -2,6 +2,8 @@ package ipaddr
 
 import (
 	"errors"
+	"net"
+	"strings"
 	"testing"
 )
 
@@ -14,7 +16,42 @@ func TestIsBroadcast(t *testing.T) {
 }
 
 func TestIsMulticast(t *testing.T) {
-	t.SkipNow()
+	data := []struct {
+		Addr string
+		Want bool
+	}{
+		{
+			Addr: "224.0.0.1",
+			Want: true,
+		},
+		{
+			Addr: "239.255.255.250",
+			Want: true,
+		},
+		{
+			Addr: "192.168.67.181",
+			Want: false,
+		},
+		{
+			Addr: "ff02::1",
+			Want: true,
+		},
+		{
+			Addr: "fe80::1",
+			Want: false,
+		},
+	}
+	for _, d := range data {
+		ip, err := ParseIP(d.Addr)
+		if err != nil {
+			t.Errorf("%s: fail to parse %s", d.Addr, err)
+			continue
+		}
+		got := ip.IsMulticast()
+		if got != d.Want {
+			t.Errorf("%s: results mismatched! want %t, got %t", d.Addr, d.Want, got)
+		}
+	}
 }
 
 func TestNetString(t *testing.T) {
@@ -52,6 +89,26 @@ func TestIPString(t *testing.T) {
 			Addr: "0:0:0:0:0::1",
 			Want: "::1",
 		},
+		{
+			Addr: "::",
+			Want: "::",
+		},
+		{
+			Addr: "2001:db8:0:1::",
+			Want: "2001:db8:0:1::",
+		},
+		{
+			Addr: "1:0:0:2:0:0:0:3",
+			Want: "1:0:0:2::3",
+		},
+		{
+			Addr: "2001:db8::1:0:0:1",
+			Want: "2001:db8::1:0:0:1",
+		},
+		{
+			Addr: "2002:c633:6401:0:d5e3:7953:13eb:22e8",
+			Want: "2002:c633:6401:0:d5e3:7953:13eb:22e8",
+		},
 	}
 	for _, d := range data {
 		ip, err := ParseIP(d.Addr)
@@ -65,6 +122,60 @@ func TestIPString(t *testing.T) {
 	}
 }
 
+func TestIPLess(t *testing.T) {
+	data := []struct {
+		Left  string
+		Right string
+		Want  bool
+	}{
+		{
+			Left:  "2001:db8::ffff",
+			Right: "2001:db9::1",
+			Want:  true,
+		},
+		{
+			Left:  "2001:db9::1",
+			Right: "2001:db8::ffff",
+			Want:  false,
+		},
+		{
+			Left:  "2001:db8::1",
+			Right: "2001:db8::2",
+			Want:  true,
+		},
+	}
+	for _, d := range data {
+		left, _ := ParseIP(d.Left)
+		right, _ := ParseIP(d.Right)
+		if got := left.Less(right); got != d.Want {
+			t.Errorf("%s < %s: results mismatched! want %t, got %t", d.Left, d.Right, d.Want, got)
+		}
+	}
+}
+
+func TestUnmap(t *testing.T) {
+	data := []struct {
+		Addr string
+		Want string
+	}{
+		{Addr: "::ffff:192.0.2.1", Want: "192.0.2.1"},
+		{Addr: "::ffff:0:0", Want: "0.0.0.0"},
+		{Addr: "192.0.2.1", Want: "192.0.2.1"},
+		{Addr: "::c000:201", Want: "::c000:201"},
+		{Addr: "2001:db8::ffff:c000:201", Want: "2001:db8::ffff:c000:201"},
+		{Addr: "fe80::1%eth0", Want: "fe80::1%eth0"},
+	}
+	for _, d := range data {
+		ip, err := ParseIP(d.Addr)
+		if err != nil {
+			t.Fatalf("%s: fail to parse %s", d.Addr, err)
+		}
+		if got := ip.Unmap(); got.String() != d.Want {
+			t.Errorf("%s: results mismatched! want %s, got %s", d.Addr, d.Want, got)
+		}
+	}
+}
+
 func TestIsLoopback(t *testing.T) {
 	data := []struct {
 		Addr string
@@ -170,6 +281,17 @@ func TestParseIP(t *testing.T) {
 			Addr: "1:2:3:4:5:6:7:8:9",
 			Err:  ErrInvalid,
 		},
+		{
+			Addr: "::ffff:192.168.1.1",
+		},
+		{
+			Addr: "1::2:3:4:5:6:7:8",
+			Err:  ErrInvalid,
+		},
+		{
+			Addr: ":1::2",
+			Err:  ErrInvalid,
+		},
 	}
 	for _, d := range data {
 		_, err := ParseIP(d.Addr)
@@ -186,3 +308,516 @@ func TestParseIP(t *testing.T) {
 		}
 	}
 }
+
+func TestParseCIDR(t *testing.T) {
+	data := []struct {
+		Addr string
+		IP   string
+		Net  string
+	}{
+		{
+			Addr: "10.1.2.3/24",
+			IP:   "10.1.2.3",
+			Net:  "10.1.2.0/24",
+		},
+		{
+			Addr: "2001:db8::1/64",
+			IP:   "2001:db8::1",
+			Net:  "2001:db8::/64",
+		},
+	}
+	for _, d := range data {
+		ip, nw, err := ParseCIDR(d.Addr)
+		if err != nil {
+			t.Errorf("%s: fail to parse %s", d.Addr, err)
+			continue
+		}
+		other, _ := ParseIP(d.IP)
+		if ip != other {
+			t.Errorf("%s: addresses mismatched! want %s, got %s", d.Addr, other, ip)
+		}
+		if got := nw.String(); got != d.Net {
+			t.Errorf("%s: networks mismatched! want %s, got %s", d.Addr, d.Net, got)
+		}
+		a, err := ParseIfAddr(d.Addr)
+		if err != nil {
+			t.Errorf("%s: fail to parse %s", d.Addr, err)
+			continue
+		}
+		if got := a.String(); got != d.Addr {
+			t.Errorf("%s: results mismatched! want %s, got %s", d.Addr, d.Addr, got)
+		}
+		if !a.Net().Equal(nw) {
+			t.Errorf("%s: networks mismatched! want %s, got %s", d.Addr, nw, a.Net())
+		}
+	}
+}
+
+func TestMustParseNet(t *testing.T) {
+	for _, str := range []string{"192.0.2.0/24", "2001:db8::/32", "0.0.0.0/0"} {
+		if nw := MustParseNet(str); nw.String() != str {
+			t.Errorf("%s: results mismatched! got %s", str, nw)
+		}
+	}
+	for _, str := range []string{"2001:db8::/129", "192.0.2.0", "192.0.2.0/"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", str)
+				}
+			}()
+			MustParseNet(str)
+		}()
+	}
+}
+
+func TestNetExclude(t *testing.T) {
+	data := []struct {
+		Net     string
+		Exclude string
+		Want    []string
+	}{
+		{
+			Net:     "192.0.2.0/24",
+			Exclude: "192.0.2.0/24",
+		},
+		{
+			Net:     "192.0.2.0/24",
+			Exclude: "198.51.100.0/24",
+			Want:    []string{"192.0.2.0/24"},
+		},
+		{
+			Net:     "192.0.2.0/24",
+			Exclude: "2001:db8::/32",
+			Want:    []string{"192.0.2.0/24"},
+		},
+		{
+			Net:     "::/0",
+			Exclude: "0.0.0.0/0",
+			Want:    []string{"::/0"},
+		},
+		{
+			Net:     "192.0.2.0/30",
+			Exclude: "192.0.2.3/32",
+			Want:    []string{"192.0.2.0/31", "192.0.2.2/32"},
+		},
+		{
+			Net:     "2001:db8::/126",
+			Exclude: "2001:db8::/128",
+			Want:    []string{"2001:db8::1/128", "2001:db8::2/127"},
+		},
+		{
+			Net:     "192.0.2.1/32",
+			Exclude: "192.0.2.0/24",
+		},
+	}
+	for _, d := range data {
+		nw, err := ParseNet(d.Net)
+		if err != nil {
+			t.Fatalf("%s: fail to parse %s", d.Net, err)
+		}
+		other, err := ParseNet(d.Exclude)
+		if err != nil {
+			t.Fatalf("%s: fail to parse %s", d.Exclude, err)
+		}
+		got := nw.Exclude(other)
+		if len(got) != len(d.Want) {
+			t.Errorf("%s - %s: length mismatched! want %d, got %d (%v)", d.Net, d.Exclude, len(d.Want), len(got), got)
+			continue
+		}
+		for i := range got {
+			if got[i].String() != d.Want[i] {
+				t.Errorf("%s - %s: results mismatched at %d! want %s, got %s", d.Net, d.Exclude, i, d.Want[i], got[i])
+			}
+		}
+	}
+}
+
+func TestExclude(t *testing.T) {
+	data := []struct {
+		Net     string
+		Exclude []string
+		Want    []string
+	}{
+		{
+			Net:     "10.0.0.0/16",
+			Exclude: []string{"10.0.255.240/28"},
+			Want: []string{
+				"10.0.0.0/17",
+				"10.0.128.0/18",
+				"10.0.192.0/19",
+				"10.0.224.0/20",
+				"10.0.240.0/21",
+				"10.0.248.0/22",
+				"10.0.252.0/23",
+				"10.0.254.0/24",
+				"10.0.255.0/25",
+				"10.0.255.128/26",
+				"10.0.255.192/27",
+				"10.0.255.224/28",
+			},
+		},
+		{
+			Net:     "192.0.2.0/28",
+			Exclude: []string{"192.0.2.1/32"},
+			Want: []string{
+				"192.0.2.0/32",
+				"192.0.2.2/31",
+				"192.0.2.4/30",
+				"192.0.2.8/29",
+			},
+		},
+		{
+			Net:     "192.0.2.0/24",
+			Exclude: []string{"192.0.2.0/26", "192.0.2.192/26"},
+			Want:    []string{"192.0.2.64/26", "192.0.2.128/26"},
+		},
+		{
+			Net:     "192.0.2.0/24",
+			Exclude: []string{"198.51.100.0/24"},
+			Want:    []string{"192.0.2.0/24"},
+		},
+		{
+			Net:     "192.0.2.0/24",
+			Exclude: []string{"192.0.0.0/16"},
+		},
+		{
+			Net:     "2001:db8::/32",
+			Exclude: []string{"2001:db8:8000::/33"},
+			Want:    []string{"2001:db8::/33"},
+		},
+	}
+	for _, d := range data {
+		nw, err := ParseNet(d.Net)
+		if err != nil {
+			t.Errorf("%s: fail to parse %s", d.Net, err)
+			continue
+		}
+		var others []Net
+		for _, str := range d.Exclude {
+			o, err := ParseNet(str)
+			if err != nil {
+				t.Fatalf("%s: fail to parse %s", str, err)
+			}
+			others = append(others, o)
+		}
+		got := nw.ExcludeAll(others...)
+		if len(got) != len(d.Want) {
+			t.Errorf("%s: length mismatched! want %d, got %d (%v)", d.Net, len(d.Want), len(got), got)
+			continue
+		}
+		for i := range got {
+			if got[i].String() != d.Want[i] {
+				t.Errorf("%s: results mismatched at %d! want %s, got %s", d.Net, i, d.Want[i], got[i])
+			}
+		}
+	}
+}
+
+func TestCollapse(t *testing.T) {
+	data := []struct {
+		Nets []string
+		Want []string
+	}{
+		{
+			Nets: []string{"192.0.2.0/25", "192.0.2.128/25"},
+			Want: []string{"192.0.2.0/24"},
+		},
+		{
+			Nets: []string{"10.0.3.0/24", "10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24", "10.0.1.128/25"},
+			Want: []string{"10.0.0.0/22"},
+		},
+		{
+			Nets: []string{"10.0.1.0/24", "10.0.2.0/24", "10.0.2.0/24"},
+			Want: []string{"10.0.1.0/24", "10.0.2.0/24"},
+		},
+		{
+			Nets: []string{"2001:db8:1::/48", "192.0.2.0/24", "2001:db8::/48", "198.51.100.0/24"},
+			Want: []string{"2001:db8::/47", "192.0.2.0/24", "198.51.100.0/24"},
+		},
+		{
+			Nets: []string{"0.0.0.0/1", "128.0.0.0/1", "10.0.0.0/8"},
+			Want: []string{"0.0.0.0/0"},
+		},
+	}
+	for _, d := range data {
+		var nets []Net
+		for _, str := range d.Nets {
+			nw, err := ParseNet(str)
+			if err != nil {
+				t.Fatalf("%s: fail to parse %s", str, err)
+			}
+			nets = append(nets, nw)
+		}
+		got := Collapse(nets...)
+		if len(got) != len(d.Want) {
+			t.Errorf("%v: length mismatched! want %d, got %d (%v)", d.Nets, len(d.Want), len(got), got)
+			continue
+		}
+		for i := range got {
+			if got[i].String() != d.Want[i] {
+				t.Errorf("%v: results mismatched at %d! want %s, got %s", d.Nets, i, d.Want[i], got[i])
+			}
+		}
+	}
+}
+
+func TestStdIP(t *testing.T) {
+	for _, str := range []string{"192.168.1.1", "2001:db8::1"} {
+		ip, _ := ParseIP(str)
+		std := ip.ToStdIP()
+		if ip.Is4() && len(std) != net.IPv4len {
+			t.Errorf("%s: IPv4 address should be 4 bytes long (got %d)", str, len(std))
+		}
+		if std.String() != str {
+			t.Errorf("%s: results mismatched! got %s", str, std)
+		}
+		back, err := FromStdIP(std)
+		if err != nil || back != ip {
+			t.Errorf("%s: roundtrip failed! got %s (%v)", str, back, err)
+		}
+	}
+}
+
+func TestStdNet(t *testing.T) {
+	for _, str := range []string{"10.1.0.0/16", "2001:db8::/32"} {
+		_, std, _ := net.ParseCIDR(str)
+		nw, err := FromStdNet(std)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", str, err)
+			continue
+		}
+		if nw.String() != str || nw.ToStdNet().String() != str {
+			t.Errorf("%s: results mismatched! got %s", str, nw)
+		}
+	}
+}
+
+func TestZone(t *testing.T) {
+	ip, err := ParseIP("fe80::1%eth0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ip.Zone() != "eth0" || ip.String() != "fe80::1%eth0" {
+		t.Errorf("zone mismatched! got %s", ip)
+	}
+	if other, _ := ParseIP("fe80::1"); ip == other || ip.Equal(other) {
+		t.Errorf("addresses with different zones should not be equal")
+	}
+}
+
+func TestParseError(t *testing.T) {
+	data := []struct {
+		Addr   string
+		Offset int
+		Reason string
+	}{
+		{Addr: "111.257.2.3", Offset: 4, Reason: "octet out of range"},
+		{Addr: "10.0.0", Offset: 6, Reason: "too few octets"},
+		{Addr: "10.0.0.1.2", Offset: 9, Reason: "too many octets"},
+		{Addr: "10.0..1", Offset: 5, Reason: "empty octet"},
+		{Addr: "10.a.0.1", Offset: 3, Reason: "unexpected character 'a'"},
+		{Addr: "1:2:3:4:5:6:7:8:9", Offset: 16, Reason: "too many groups"},
+		{Addr: "2001::db8::1", Offset: 9, Reason: "multiple ::"},
+		{Addr: "2001:db8:12345::1", Offset: 9, Reason: "group exceeds 4 hex digits"},
+		{Addr: "2001:db8::1:", Offset: 11, Reason: "trailing single colon"},
+		{Addr: "1:1", Offset: 3, Reason: "too few groups"},
+		{Addr: "::ffff:1.2.3.300", Offset: 13, Reason: "octet out of range"},
+		{Addr: "10.0.0.0/33", Offset: 9, Reason: "prefix length exceeds 32"},
+		{Addr: "2001:db8::/129", Offset: 11, Reason: "prefix length exceeds 128"},
+		{Addr: "10.0.0.0/2x", Offset: 10, Reason: "unexpected character 'x'"},
+		{Addr: "10.300.0.0/8", Offset: 3, Reason: "octet out of range"},
+		{Addr: "10.0.0.0", Offset: 8, Reason: "missing prefix length"},
+	}
+	for _, d := range data {
+		var err error
+		if strings.Contains(d.Addr, "/") || d.Reason == "missing prefix length" {
+			_, _, err = ParseCIDR(d.Addr)
+		} else {
+			_, err = ParseIP(d.Addr)
+		}
+		if !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: error should match ErrInvalid (got %v)", d.Addr, err)
+			continue
+		}
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("%s: ParseError expected (got %T)", d.Addr, err)
+			continue
+		}
+		if pe.Input != d.Addr || pe.Offset != d.Offset || pe.Reason != d.Reason {
+			t.Errorf("%s: errors mismatched! want %q at %d, got %q at %d (input %q)", d.Addr, d.Reason, d.Offset, pe.Reason, pe.Offset, pe.Input)
+		}
+	}
+}
+
+func TestParseIPBytes(t *testing.T) {
+	for _, str := range []string{"192.168.1.1", "2001:db8::1", "fe80::1%eth0"} {
+		b := []byte(str)
+		ip, err := ParseIPBytes(b)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", str, err)
+			continue
+		}
+		copy(b, "xxxxxxxxxxxxxxxxxxxxxxxxxxxxxx")
+		if got := ip.String(); got != str {
+			t.Errorf("%s: results mismatched! got %s", str, got)
+		}
+	}
+	b := []byte("10.0.0.300")
+	_, err := ParseIPBytes(b)
+	copy(b, "xxxxxxxxxx")
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Input != "10.0.0.300" {
+		t.Errorf("ParseError should keep a copy of its input (got %v)", err)
+	}
+}
+
+func TestAllocs(t *testing.T) {
+	var (
+		ip4, _ = ParseIP("192.168.67.181")
+		ip6, _ = ParseIP("2001:db8:aaaa:dead:beef:cafe:0:1")
+		nw, _  = ParseNet("2001:db8::/32")
+		in4    = []byte("192.168.67.181")
+		in6    = []byte("2001:db8:aaaa:dead:beef:cafe:0:1")
+		buf    = make([]byte, 0, 64)
+	)
+	data := []struct {
+		Name string
+		Func func()
+	}{
+		{Name: "ParseIP/v4", Func: func() { ParseIP("192.168.67.181") }},
+		{Name: "ParseIP/v6", Func: func() { ParseIP("2001:db8:aaaa:dead:beef:cafe:0:1") }},
+		{Name: "ParseIPBytes/v4", Func: func() { ParseIPBytes(in4) }},
+		{Name: "ParseIPBytes/v6", Func: func() { ParseIPBytes(in6) }},
+		{Name: "ParseNet", Func: func() { ParseNet("2001:db8::/32") }},
+		{Name: "AppendTo/v4", Func: func() { ip4.AppendTo(buf[:0]) }},
+		{Name: "AppendTo/v6", Func: func() { ip6.AppendTo(buf[:0]) }},
+		{Name: "AppendTo/net", Func: func() { nw.AppendTo(buf[:0]) }},
+	}
+	for _, d := range data {
+		if n := testing.AllocsPerRun(100, d.Func); n != 0 {
+			t.Errorf("%s: unexpected allocations! want 0, got %.1f", d.Name, n)
+		}
+	}
+}
+
+func BenchmarkParseIP(b *testing.B) {
+	for _, str := range []string{"192.168.67.181", "2001:db8:aaaa:dead:beef:cafe:0:1"} {
+		b.Run(str, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ParseIP(str)
+			}
+		})
+	}
+}
+
+func BenchmarkParseIPBytes(b *testing.B) {
+	for _, str := range []string{"192.168.67.181", "2001:db8:aaaa:dead:beef:cafe:0:1"} {
+		in := []byte(str)
+		b.Run(str, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ParseIPBytes(in)
+			}
+		})
+	}
+}
+
+func BenchmarkAppendTo(b *testing.B) {
+	for _, str := range []string{"192.168.67.181", "2001:db8:aaaa:dead:beef:cafe:0:1"} {
+		var (
+			ip, _ = ParseIP(str)
+			buf   = make([]byte, 0, 64)
+		)
+		b.Run(str, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf = ip.AppendTo(buf[:0])
+			}
+		})
+	}
+}
+
+func TestNetTraversal(t *testing.T) {
+	data := []struct {
+		Net    string
+		Next   string
+		Prev   string
+		Parent string
+		Lo     string
+		Hi     string
+	}{
+		{
+			Net:    "10.0.1.0/24",
+			Next:   "10.0.2.0/24",
+			Prev:   "10.0.0.0/24",
+			Parent: "10.0.0.0/23",
+			Lo:     "10.0.1.0/25",
+			Hi:     "10.0.1.128/25",
+		},
+		{
+			Net:    "192.168.0.64/26",
+			Next:   "192.168.0.128/26",
+			Prev:   "192.168.0.0/26",
+			Parent: "192.168.0.0/25",
+			Lo:     "192.168.0.64/27",
+			Hi:     "192.168.0.96/27",
+		},
+		{
+			Net:    "2001:db8:1::/48",
+			Next:   "2001:db8:2::/48",
+			Prev:   "2001:db8::/48",
+			Parent: "2001:db8::/47",
+			Lo:     "2001:db8:1::/49",
+			Hi:     "2001:db8:1:8000::/49",
+		},
+	}
+	for _, d := range data {
+		nw, _ := ParseNet(d.Net)
+		next, err := nw.Next()
+		if err != nil || next.String() != d.Next {
+			t.Errorf("%s: next mismatched! want %s, got %s (%v)", d.Net, d.Next, next, err)
+		}
+		prev, err := nw.Prev()
+		if err != nil || prev.String() != d.Prev {
+			t.Errorf("%s: prev mismatched! want %s, got %s (%v)", d.Net, d.Prev, prev, err)
+		}
+		parent, err := nw.Parent()
+		if err != nil || parent.String() != d.Parent {
+			t.Errorf("%s: parent mismatched! want %s, got %s (%v)", d.Net, d.Parent, parent, err)
+		}
+		lo, hi, err := nw.Children()
+		if err != nil || lo.String() != d.Lo || hi.String() != d.Hi {
+			t.Errorf("%s: children mismatched! want %s %s, got %s %s (%v)", d.Net, d.Lo, d.Hi, lo, hi, err)
+		}
+	}
+}
+
+func TestNetTraversalEdges(t *testing.T) {
+	var (
+		last, _  = ParseNet("255.255.255.0/24")
+		first, _ = ParseNet("0.0.0.0/24")
+		all, _   = ParseNet("::/0")
+		host, _  = ParseNet("10.0.0.1/32")
+	)
+	if _, err := last.Next(); !errors.Is(err, ErrRange) {
+		t.Errorf("%s: next should fail (got %v)", last, err)
+	}
+	if _, err := first.Prev(); !errors.Is(err, ErrRange) {
+		t.Errorf("%s: prev should fail (got %v)", first, err)
+	}
+	if _, err := all.Parent(); !errors.Is(err, ErrRange) {
+		t.Errorf("%s: parent should fail (got %v)", all, err)
+	}
+	if _, _, err := host.Children(); !errors.Is(err, ErrRange) {
+		t.Errorf("%s: children should fail (got %v)", host, err)
+	}
+	if _, err := host.Supernet(33); !errors.Is(err, ErrRange) {
+		t.Errorf("%s: supernet should fail (got %v)", host, err)
+	}
+	if nw, err := host.Supernet(8); err != nil || nw.String() != "10.0.0.0/8" {
+		t.Errorf("%s: supernet mismatched! want 10.0.0.0/8, got %s (%v)", host, nw, err)
+	}
+}
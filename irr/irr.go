@@ -0,0 +1,439 @@
+// Package irr reads RPSL objects (RFC 2622) from IRR database dumps and
+// resolves as-set and route-set objects into the prefixes they cover, much
+// like bgpq4 does against a live IRR server.
+package irr
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/midbel/ipaddr"
+	"github.com/midbel/ipaddr/rpki"
+)
+
+var ErrNotFound = errors.New("irr: object not found")
+
+const magicGzip = "\x1f\x8b"
+
+type Attr struct {
+	Name  string
+	Value string
+}
+
+type Object struct {
+	Class string
+	Key   string
+	Attrs []Attr
+}
+
+func (o Object) Get(name string) string {
+	for _, a := range o.Attrs {
+		if a.Name == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (o Object) All(name string) []string {
+	var list []string
+	for _, a := range o.Attrs {
+		if a.Name == name {
+			list = append(list, a.Value)
+		}
+	}
+	return list
+}
+
+type Reader struct {
+	scan *bufio.Scanner
+	line int
+}
+
+func NewReader(r io.Reader) *Reader {
+	scan := bufio.NewScanner(r)
+	scan.Buffer(make([]byte, 0, 4096), 1<<20)
+	return &Reader{scan: scan}
+}
+
+func (r *Reader) Next() (*Object, error) {
+	var obj Object
+	for r.scan.Scan() {
+		r.line++
+		line := r.scan.Text()
+		if strings.TrimSpace(line) == "" {
+			if len(obj.Attrs) > 0 {
+				return &obj, nil
+			}
+			continue
+		}
+		switch line[0] {
+		case '%', '#':
+			continue
+		case ' ', '\t', '+':
+			if len(obj.Attrs) == 0 {
+				return nil, fmt.Errorf("irr: line %d: continuation line outside of object", r.line)
+			}
+			a := &obj.Attrs[len(obj.Attrs)-1]
+			if v := cleanValue(line[1:]); v != "" {
+				if a.Value != "" {
+					a.Value += " "
+				}
+				a.Value += v
+			}
+			continue
+		}
+		x := strings.IndexByte(line, ':')
+		if x <= 0 {
+			return nil, fmt.Errorf("irr: line %d: missing attribute name", r.line)
+		}
+		a := Attr{
+			Name:  strings.ToLower(strings.TrimSpace(line[:x])),
+			Value: cleanValue(line[x+1:]),
+		}
+		if len(obj.Attrs) == 0 {
+			obj.Class, obj.Key = a.Name, a.Value
+		}
+		obj.Attrs = append(obj.Attrs, a)
+	}
+	if err := r.scan.Err(); err != nil {
+		return nil, err
+	}
+	if len(obj.Attrs) > 0 {
+		return &obj, nil
+	}
+	return nil, io.EOF
+}
+
+func cleanValue(str string) string {
+	if x := strings.IndexByte(str, '#'); x >= 0 {
+		str = str[:x]
+	}
+	return strings.TrimSpace(str)
+}
+
+type Route struct {
+	Prefix ipaddr.Net
+	Origin rpki.ASN
+	Source string
+}
+
+func RouteFromObject(obj *Object) (Route, error) {
+	var r Route
+	if obj.Class != "route" && obj.Class != "route6" {
+		return r, fmt.Errorf("irr: %s: not a route object", obj.Class)
+	}
+	nw, err := ipaddr.ParseNet(obj.Key)
+	if err != nil {
+		return r, fmt.Errorf("irr: %s: %w", obj.Key, err)
+	}
+	if nw.Address().Is6() != (obj.Class == "route6") {
+		return r, fmt.Errorf("irr: %s: family mismatch for %s object", obj.Key, obj.Class)
+	}
+	asn, err := rpki.ParseASN(obj.Get("origin"))
+	if err != nil {
+		return r, fmt.Errorf("irr: %s: %w", obj.Key, err)
+	}
+	r.Prefix, r.Origin, r.Source = nw, asn, obj.Get("source")
+	return r, nil
+}
+
+type Set struct {
+	Name    string
+	Members []string
+}
+
+func SetFromObject(obj *Object) (Set, error) {
+	s := Set{Name: strings.ToUpper(obj.Key)}
+	if obj.Class != "as-set" && obj.Class != "route-set" {
+		return s, fmt.Errorf("irr: %s: not a set object", obj.Class)
+	}
+	for _, a := range obj.Attrs {
+		if a.Name != "members" && a.Name != "mp-members" {
+			continue
+		}
+		for _, m := range strings.Split(a.Value, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				s.Members = append(s.Members, m)
+			}
+		}
+	}
+	return s, nil
+}
+
+// Registry indexes the route objects per origin and the set objects per
+// name. Objects of other classes are ignored. A Registry is not safe for
+// concurrent use.
+type Registry struct {
+	routes map[rpki.ASN][]ipaddr.Net
+	sets   map[string][]string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		routes: make(map[rpki.ASN][]ipaddr.Net),
+		sets:   make(map[string][]string),
+	}
+}
+
+func (r *Registry) Load(rd io.Reader) error {
+	rs := bufio.NewReader(rd)
+	if magic, _ := rs.Peek(len(magicGzip)); bytes.Equal(magic, []byte(magicGzip)) {
+		z, err := gzip.NewReader(rs)
+		if err != nil {
+			return err
+		}
+		defer z.Close()
+		rs = bufio.NewReader(z)
+	}
+	objs := NewReader(rs)
+	for {
+		obj, err := objs.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch obj.Class {
+		case "route", "route6":
+			rt, err := RouteFromObject(obj)
+			if err != nil {
+				return err
+			}
+			r.AddRoute(rt)
+		case "as-set", "route-set":
+			s, err := SetFromObject(obj)
+			if err != nil {
+				return err
+			}
+			r.AddSet(s)
+		}
+	}
+}
+
+func (r *Registry) LoadFile(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return r.Load(f)
+}
+
+func (r *Registry) AddRoute(rt Route) {
+	r.routes[rt.Origin] = append(r.routes[rt.Origin], rt.Prefix)
+}
+
+func (r *Registry) AddSet(s Set) {
+	name := strings.ToUpper(s.Name)
+	r.sets[name] = append(r.sets[name], s.Members...)
+}
+
+// Routes returns the prefixes registered for an AS number as they are in the
+// route objects.
+func (r *Registry) Routes(asn rpki.ASN) []ipaddr.Net {
+	list := make([]ipaddr.PrefixRange, 0, len(r.routes[asn]))
+	for _, nw := range r.routes[asn] {
+		list = append(list, ipaddr.ExactRange(nw))
+	}
+	list = sortRanges(list)
+	nets := make([]ipaddr.Net, 0, len(list))
+	for _, pr := range list {
+		nets = append(nets, pr.Net)
+	}
+	return nets
+}
+
+// Expand returns the AS numbers of an as-set, following the nested sets.
+// Nested sets missing from the registry are ignored.
+func (r *Registry) Expand(name string) ([]rpki.ASN, error) {
+	if asn, err := rpki.ParseASN(name); err == nil {
+		return []rpki.ASN{asn}, nil
+	}
+	name = strings.ToUpper(name)
+	if _, ok := r.sets[name]; !ok {
+		return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	var (
+		list []rpki.ASN
+		seen = make(map[string]bool)
+		done = make(map[rpki.ASN]bool)
+	)
+	r.expand(name, seen, func(asn rpki.ASN) {
+		if !done[asn] {
+			done[asn] = true
+			list = append(list, asn)
+		}
+	})
+	return list, nil
+}
+
+// Resolve returns the prefix ranges covered by an AS number, an as-set or a
+// route-set. Registered routes are kept as they are, without aggregation, so
+// that the list accepts exactly what the objects describe. The RPSL range
+// operators (^-, ^+, ^n and ^n-m) of route-set members are translated into
+// prefix ranges.
+func (r *Registry) Resolve(name string) ([]ipaddr.PrefixRange, error) {
+	if asn, err := rpki.ParseASN(name); err == nil {
+		var list []ipaddr.PrefixRange
+		for _, nw := range r.routes[asn] {
+			list = append(list, ipaddr.ExactRange(nw))
+		}
+		return sortRanges(list), nil
+	}
+	name = strings.ToUpper(name)
+	if _, ok := r.sets[name]; !ok {
+		return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	var (
+		list []ipaddr.PrefixRange
+		seen = make(map[string]bool)
+	)
+	if err := r.resolve(name, "", seen, &list); err != nil {
+		return nil, err
+	}
+	return sortRanges(list), nil
+}
+
+func (r *Registry) expand(name string, seen map[string]bool, fn func(rpki.ASN)) {
+	if seen[name] {
+		return
+	}
+	seen[name] = true
+	for _, m := range r.sets[name] {
+		if asn, err := rpki.ParseASN(m); err == nil {
+			fn(asn)
+			continue
+		}
+		r.expand(strings.ToUpper(m), seen, fn)
+	}
+}
+
+func (r *Registry) resolve(name, op string, seen map[string]bool, list *[]ipaddr.PrefixRange) error {
+	if seen[name] {
+		return nil
+	}
+	seen[name] = true
+	add := func(op string, nets ...ipaddr.Net) error {
+		for _, nw := range nets {
+			pr, err := applyRange(nw, op)
+			if err != nil {
+				return err
+			}
+			*list = append(*list, pr)
+		}
+		return nil
+	}
+	for _, m := range r.sets[name] {
+		mop := op
+		if x := strings.IndexByte(m, '^'); x >= 0 {
+			// the operator of a set replaces the one of its members
+			if mop == "" {
+				mop = m[x:]
+			}
+			m = m[:x]
+		}
+		var err error
+		if nw, e := ipaddr.ParseNet(m); e == nil {
+			err = add(mop, nw)
+		} else if asn, e := rpki.ParseASN(m); e == nil {
+			err = add(mop, r.routes[asn]...)
+		} else if m = strings.ToUpper(m); isASSet(m) {
+			r.expand(m, seen, func(asn rpki.ASN) {
+				if err == nil {
+					err = add(mop, r.routes[asn]...)
+				}
+			})
+		} else {
+			err = r.resolve(m, mop, seen, list)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyRange translates a RPSL range operator into a prefix range.
+func applyRange(nw ipaddr.Net, op string) (ipaddr.PrefixRange, error) {
+	var (
+		pr   = ipaddr.ExactRange(nw)
+		bits = 32
+		err  error
+	)
+	if nw.Address().Is6() {
+		bits = 128
+	}
+	switch op {
+	case "":
+	case "^-":
+		pr.Min, pr.Max = pr.Min+1, bits
+	case "^+":
+		pr.Max = bits
+	default:
+		str := strings.TrimPrefix(op, "^")
+		if x := strings.IndexByte(str, '-'); x >= 0 {
+			if pr.Min, err = strconv.Atoi(str[:x]); err == nil {
+				pr.Max, err = strconv.Atoi(str[x+1:])
+			}
+		} else {
+			pr.Min, err = strconv.Atoi(str)
+			pr.Max = pr.Min
+		}
+	}
+	if err != nil || pr.Min < nw.Size() || pr.Min > pr.Max || pr.Max > bits {
+		return pr, fmt.Errorf("%s%s: invalid range operator: %w", nw, op, ipaddr.ErrInvalid)
+	}
+	return pr, nil
+}
+
+func sortRanges(list []ipaddr.PrefixRange) []ipaddr.PrefixRange {
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		switch {
+		case !a.Net.Address().Equal(b.Net.Address()):
+			return a.Net.Address().Less(b.Net.Address())
+		case a.Net.Size() != b.Net.Size():
+			return a.Net.Size() < b.Net.Size()
+		case a.Min != b.Min:
+			return a.Min < b.Min
+		default:
+			return a.Max < b.Max
+		}
+	})
+	var res []ipaddr.PrefixRange
+	for _, pr := range list {
+		if n := len(res); n > 0 && res[n-1] == pr {
+			continue
+		}
+		res = append(res, pr)
+	}
+	return res
+}
+
+func isASSet(name string) bool {
+	if x := strings.LastIndexByte(name, ':'); x >= 0 {
+		name = name[x+1:]
+	}
+	return strings.HasPrefix(name, "AS-")
+}
+
+func PrefixList(name string, ranges []ipaddr.PrefixRange) *ipaddr.PrefixList {
+	list := ipaddr.PrefixList{Name: name}
+	for _, pr := range ranges {
+		list.Add(ipaddr.PrefixEntry{
+			Action: ipaddr.PrefixPermit,
+			Range:  pr,
+		})
+	}
+	return &list
+}
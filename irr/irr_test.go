@@ -0,0 +1,211 @@
+package irr
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/midbel/ipaddr"
+	"github.com/midbel/ipaddr/rpki"
+)
+
+const dump = `
+% RIPE database dump
+# comment
+
+route:          192.0.2.0/25
+descr:          first half
+origin:         AS64500
+source:         RIPE
+
+route:          192.0.2.128/25
+origin:         AS64500 # same origin
+source:         RIPE
+
+route6:         2001:db8::/32
+origin:         AS64501
+source:         RIPE
+
+route:          198.51.100.0/24
+origin:         AS64502
+source:         RADB
+
+route:          203.0.113.0/24
+origin:         AS64503
+source:         RADB
+
+as-set:         AS-CUSTOMERS
+members:        AS64500,
+                AS-DOWNSTREAM
++
+mp-members:     AS64501
+source:         RIPE
+
+as-set:         AS-DOWNSTREAM
+members:        AS64502, AS-CUSTOMERS
+source:         RIPE
+
+route-set:      RS-STATIC
+members:        10.0.0.0/24^+, 10.0.1.0/24
+mp-members:     AS64503, AS-DOWNSTREAM
+source:         RIPE
+
+route-set:      RS-RANGES
+members:        10.2.0.0/16^-, 10.3.0.0/16^24, 10.4.0.0/16^20-24,
+                AS64500^26, RS-NESTED^+
+source:         RIPE
+
+route-set:      RS-NESTED
+members:        10.5.0.0/16^24, 10.6.0.0/16
+source:         RIPE
+
+route-set:      RS-INVALID
+members:        10.7.0.0/16^8
+source:         RIPE
+`
+
+func TestReader(t *testing.T) {
+	var (
+		r     = NewReader(strings.NewReader(dump))
+		count int
+	)
+	for {
+		obj, err := r.Next()
+		if err != nil {
+			break
+		}
+		count++
+		if obj.Key == "AS-CUSTOMERS" {
+			want := "AS64500, AS-DOWNSTREAM"
+			if got := obj.Get("members"); got != want {
+				t.Errorf("members mismatched! want %q, got %q", want, got)
+			}
+		}
+		if obj.Key == "192.0.2.128/25" && obj.Get("origin") != "AS64500" {
+			t.Errorf("origin mismatched! got %q", obj.Get("origin"))
+		}
+	}
+	if count != 11 {
+		t.Fatalf("objects count mismatched! want 11, got %d", count)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	var buf bytes.Buffer
+	z := gzip.NewWriter(&buf)
+	z.Write([]byte(dump))
+	z.Close()
+
+	for _, r := range []*bytes.Reader{bytes.NewReader([]byte(dump)), bytes.NewReader(buf.Bytes())} {
+		reg := NewRegistry()
+		if err := reg.Load(r); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		data := []struct {
+			Name string
+			Want []string
+		}{
+			{
+				Name: "AS64500",
+				Want: []string{"192.0.2.0/25", "192.0.2.128/25"},
+			},
+			{
+				Name: "as-customers",
+				Want: []string{"2001:db8::/32", "192.0.2.0/25", "192.0.2.128/25", "198.51.100.0/24"},
+			},
+			{
+				Name: "RS-STATIC",
+				Want: []string{"2001:db8::/32", "10.0.0.0/24 le 32", "10.0.1.0/24", "192.0.2.0/25", "192.0.2.128/25", "198.51.100.0/24", "203.0.113.0/24"},
+			},
+			{
+				Name: "RS-RANGES",
+				Want: []string{
+					"10.2.0.0/16 ge 17",
+					"10.3.0.0/16 ge 24 le 24",
+					"10.4.0.0/16 ge 20 le 24",
+					"10.5.0.0/16 le 32",
+					"10.6.0.0/16 le 32",
+					"192.0.2.0/25 ge 26 le 26",
+					"192.0.2.128/25 ge 26 le 26",
+				},
+			},
+		}
+		for _, d := range data {
+			got, err := reg.Resolve(d.Name)
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", d.Name, err)
+				continue
+			}
+			if len(got) != len(d.Want) {
+				t.Errorf("%s: length mismatched! want %d, got %d (%v)", d.Name, len(d.Want), len(got), got)
+				continue
+			}
+			for i := range got {
+				if got[i].String() != d.Want[i] {
+					t.Errorf("%s: results mismatched at %d! want %s, got %s", d.Name, i, d.Want[i], got[i])
+				}
+			}
+		}
+		asns, err := reg.Expand("AS-CUSTOMERS")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		want := []rpki.ASN{64500, 64502, 64501}
+		if len(asns) != len(want) {
+			t.Fatalf("as-set mismatched! want %v, got %v", want, asns)
+		}
+		for i := range want {
+			if asns[i] != want[i] {
+				t.Errorf("as-set mismatched at %d! want %s, got %s", i, want[i], asns[i])
+			}
+		}
+		if _, err := reg.Resolve("RS-INVALID"); !errors.Is(err, ipaddr.ErrInvalid) {
+			t.Errorf("RS-INVALID: expected ErrInvalid, got %v", err)
+		}
+		if _, err := reg.Resolve("AS-UNKNOWN"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("AS-UNKNOWN: expected ErrNotFound, got %v", err)
+		}
+	}
+}
+
+func TestPrefixList(t *testing.T) {
+	reg := NewRegistry()
+	if err := reg.Load(strings.NewReader(dump)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	nets, err := reg.Resolve("AS-CUSTOMERS")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var str strings.Builder
+	if _, err := PrefixList("CUSTOMERS", nets).WriteTo(&str); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := `ipv6 prefix-list CUSTOMERS seq 5 permit 2001:db8::/32
+ip prefix-list CUSTOMERS seq 10 permit 192.0.2.0/25
+ip prefix-list CUSTOMERS seq 15 permit 192.0.2.128/25
+ip prefix-list CUSTOMERS seq 20 permit 198.51.100.0/24
+`
+	if got := str.String(); got != want {
+		t.Errorf("prefix-list mismatched! want %q, got %q", want, got)
+	}
+
+	if nets, err = reg.Resolve("RS-STATIC"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	list := PrefixList("STATIC", nets)
+	for _, str := range []string{"192.0.2.128/25", "10.0.0.0/24", "10.0.0.128/25"} {
+		nw, _ := ipaddr.ParseNet(str)
+		if !list.Permit(nw) {
+			t.Errorf("%s: announcement should be permitted", str)
+		}
+	}
+	for _, str := range []string{"192.0.2.0/24", "10.0.1.0/25"} {
+		nw, _ := ipaddr.ParseNet(str)
+		if list.Permit(nw) {
+			t.Errorf("%s: announcement should be denied", str)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package mmdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+)
+
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+const maxDepth = 64
+
+var uintSizes = map[int]uint{
+	typeUint16: 2,
+	typeUint32: 4,
+	typeUint64: 8,
+}
+
+type decoder struct {
+	buf []byte
+}
+
+func (d decoder) decode(off uint) (interface{}, uint, error) {
+	return d.decodeDepth(off, 0)
+}
+
+// capacity bounds the number of elements announced by a map or an array by
+// the bytes left after off since each element takes at least one byte.
+func (d decoder) capacity(off, size uint) uint {
+	if off >= uint(len(d.buf)) {
+		return 0
+	}
+	if left := uint(len(d.buf)) - off; size > left {
+		return left
+	}
+	return size
+}
+
+func (d decoder) decodeDepth(off uint, depth int) (interface{}, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, fmt.Errorf("%w: data nested too deeply", ErrCorrupted)
+	}
+	typ, size, off, err := d.control(off)
+	if err != nil {
+		return nil, 0, err
+	}
+	if typ == typePointer {
+		ptr, next, err := d.pointer(size, off)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decodeDepth(ptr, depth+1)
+		return v, next, err
+	}
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{}, d.capacity(off, size))
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decodeDepth(off, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("%w: map key is %T", ErrCorrupted, k)
+			}
+			v, next, err := d.decodeDepth(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key], off = v, next
+		}
+		return m, off, nil
+	case typeArray:
+		list := make([]interface{}, 0, d.capacity(off, size))
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decodeDepth(off, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			list, off = append(list, v), next
+		}
+		return list, off, nil
+	case typeBool:
+		if size > 1 {
+			return nil, 0, fmt.Errorf("%w: invalid boolean size %d", ErrCorrupted, size)
+		}
+		return size == 1, off, nil
+	}
+	b, err := d.bytes(off, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	off += size
+	switch typ {
+	case typeString:
+		return string(b), off, nil
+	case typeBytes:
+		return append([]byte(nil), b...), off, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("%w: invalid double size %d", ErrCorrupted, size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), off, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("%w: invalid float size %d", ErrCorrupted, size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), off, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > uintSizes[typ] {
+			return nil, 0, fmt.Errorf("%w: invalid integer size %d", ErrCorrupted, size)
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, off, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("%w: invalid integer size %d", ErrCorrupted, size)
+		}
+		var v uint32
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int(int32(v)), off, nil
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, fmt.Errorf("%w: invalid integer size %d", ErrCorrupted, size)
+		}
+		return new(big.Int).SetBytes(b), off, nil
+	default:
+		return nil, 0, fmt.Errorf("%w: unknown data type %d", ErrCorrupted, typ)
+	}
+}
+
+func (d decoder) control(off uint) (int, uint, uint, error) {
+	b, err := d.bytes(off, 1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	off++
+	var (
+		typ  = int(b[0] >> 5)
+		size = uint(b[0] & 0x1f)
+	)
+	if typ == typeExtended {
+		x, err := d.bytes(off, 1)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		typ, off = int(x[0])+7, off+1
+		if typ < typeInt32 {
+			return 0, 0, 0, fmt.Errorf("%w: invalid extended type %d", ErrCorrupted, typ)
+		}
+	}
+	if typ == typePointer || size < 29 {
+		return typ, size, off, nil
+	}
+	n := size - 28
+	x, err := d.bytes(off, n)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	var v uint
+	for _, c := range x {
+		v = v<<8 | uint(c)
+	}
+	switch n {
+	case 1:
+		size = 29 + v
+	case 2:
+		size = 285 + v
+	default:
+		size = 65821 + v
+	}
+	return typ, size, off + n, nil
+}
+
+func (d decoder) pointer(size, off uint) (uint, uint, error) {
+	n := (size>>3)&0x3 + 1
+	b, err := d.bytes(off, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	var v uint
+	if n < 4 {
+		v = size & 0x7
+	}
+	for _, c := range b {
+		v = v<<8 | uint(c)
+	}
+	switch n {
+	case 2:
+		v += 2048
+	case 3:
+		v += 526336
+	}
+	return v, off + n, nil
+}
+
+func (d decoder) bytes(off, size uint) ([]byte, error) {
+	if off+size > uint(len(d.buf)) || off+size < off {
+		return nil, fmt.Errorf("%w: unexpected end of data", ErrCorrupted)
+	}
+	return d.buf[off : off+size], nil
+}
+
+var bigIntType = reflect.TypeOf(big.Int{})
+
+func assign(dst reflect.Value, src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	switch dst.Kind() {
+	case reflect.Interface:
+		if dst.NumMethod() == 0 {
+			dst.Set(reflect.ValueOf(src))
+			return nil
+		}
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return assign(dst.Elem(), src)
+	case reflect.Struct:
+		if dst.Type() == bigIntType {
+			if v, ok := src.(*big.Int); ok {
+				dst.Set(reflect.ValueOf(*v))
+				return nil
+			}
+			break
+		}
+		m, ok := src.(map[string]interface{})
+		if !ok {
+			break
+		}
+		return assignStruct(dst, m)
+	case reflect.Map:
+		m, ok := src.(map[string]interface{})
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			break
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(dst.Type(), len(m)))
+		}
+		for k, v := range m {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if err := assign(elem, v); err != nil {
+				return err
+			}
+			dst.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), elem)
+		}
+		return nil
+	case reflect.Slice:
+		if b, ok := src.([]byte); ok && dst.Type().Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes(b)
+			return nil
+		}
+		list, ok := src.([]interface{})
+		if !ok {
+			break
+		}
+		s := reflect.MakeSlice(dst.Type(), len(list), len(list))
+		for i, v := range list {
+			if err := assign(s.Index(i), v); err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+		return nil
+	case reflect.String:
+		if v, ok := src.(string); ok {
+			dst.SetString(v)
+			return nil
+		}
+	case reflect.Bool:
+		if v, ok := src.(bool); ok {
+			dst.SetBool(v)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch v := src.(type) {
+		case float64:
+			dst.SetFloat(v)
+			return nil
+		case float32:
+			dst.SetFloat(float64(v))
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch v := src.(type) {
+		case int:
+			n = int64(v)
+		case uint64:
+			if v > math.MaxInt64 {
+				return fmt.Errorf("mmdb: %d overflows %s", v, dst.Type())
+			}
+			n = int64(v)
+		default:
+			return fmt.Errorf("mmdb: cannot decode %T into %s", src, dst.Type())
+		}
+		if dst.OverflowInt(n) {
+			return fmt.Errorf("mmdb: %d overflows %s", n, dst.Type())
+		}
+		dst.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		switch v := src.(type) {
+		case uint64:
+			n = v
+		case int:
+			if v < 0 {
+				return fmt.Errorf("mmdb: %d overflows %s", v, dst.Type())
+			}
+			n = uint64(v)
+		default:
+			return fmt.Errorf("mmdb: cannot decode %T into %s", src, dst.Type())
+		}
+		if dst.OverflowUint(n) {
+			return fmt.Errorf("mmdb: %d overflows %s", n, dst.Type())
+		}
+		dst.SetUint(n)
+		return nil
+	}
+	return fmt.Errorf("mmdb: cannot decode %T into %s", src, dst.Type())
+}
+
+func assignStruct(dst reflect.Value, m map[string]interface{}) error {
+	typ := dst.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("maxminddb"); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		v, ok := m[name]
+		if !ok {
+			continue
+		}
+		if err := assign(dst.Field(i), v); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package mmdb
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+)
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) encode(v interface{}) error {
+	if v == nil {
+		return fmt.Errorf("mmdb: can not encode nil value")
+	}
+	return e.encodeValue(reflect.ValueOf(v), 0)
+}
+
+func (e *encoder) encodeValue(v reflect.Value, depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("mmdb: value nested too deeply")
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return fmt.Errorf("mmdb: can not encode nil %s", v.Type())
+		}
+		if v.Type() == reflect.PtrTo(bigIntType) {
+			return e.encodeBigInt(v.Interface().(*big.Int))
+		}
+		return e.encodeValue(v.Elem(), depth+1)
+	case reflect.String:
+		e.control(typeString, v.Len())
+		e.buf = append(e.buf, v.String()...)
+	case reflect.Bool:
+		size := 0
+		if v.Bool() {
+			size = 1
+		}
+		e.control(typeBool, size)
+	case reflect.Float64:
+		e.control(typeDouble, 8)
+		e.buf = appendUint(e.buf, math.Float64bits(v.Float()), 8)
+	case reflect.Float32:
+		e.control(typeFloat, 4)
+		e.buf = appendUint(e.buf, uint64(math.Float32bits(float32(v.Float()))), 4)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := v.Int()
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			return fmt.Errorf("mmdb: %d overflows int32", n)
+		}
+		e.encodeUint(typeInt32, uint64(uint32(n)))
+	case reflect.Uint8, reflect.Uint16:
+		e.encodeUint(typeUint16, v.Uint())
+	case reflect.Uint32:
+		e.encodeUint(typeUint32, v.Uint())
+	case reflect.Uint, reflect.Uint64:
+		e.encodeUint(typeUint64, v.Uint())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.control(typeBytes, v.Len())
+			for i := 0; i < v.Len(); i++ {
+				e.buf = append(e.buf, byte(v.Index(i).Uint()))
+			}
+			break
+		}
+		e.control(typeArray, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if err := e.encodeValue(v.Index(i), depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("mmdb: map key must be a string (got %s)", v.Type().Key())
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		e.control(typeMap, len(keys))
+		for _, k := range keys {
+			e.control(typeString, k.Len())
+			e.buf = append(e.buf, k.String()...)
+			if err := e.encodeValue(v.MapIndex(k), depth+1); err != nil {
+				return fmt.Errorf("%s: %w", k, err)
+			}
+		}
+	case reflect.Struct:
+		if v.Type() == bigIntType {
+			n := v.Interface().(big.Int)
+			return e.encodeBigInt(&n)
+		}
+		return e.encodeStruct(v, depth)
+	default:
+		return fmt.Errorf("mmdb: can not encode %s", v.Type())
+	}
+	return nil
+}
+
+func (e *encoder) encodeStruct(v reflect.Value, depth int) error {
+	var (
+		typ    = v.Type()
+		names  []string
+		fields []reflect.Value
+	)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("maxminddb"); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		names, fields = append(names, name), append(fields, v.Field(i))
+	}
+	e.control(typeMap, len(names))
+	for i, name := range names {
+		e.control(typeString, len(name))
+		e.buf = append(e.buf, name...)
+		if err := e.encodeValue(fields[i], depth+1); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (e *encoder) encodeBigInt(n *big.Int) error {
+	if n.Sign() < 0 || n.BitLen() > 128 {
+		return fmt.Errorf("mmdb: %s overflows uint128", n)
+	}
+	b := n.Bytes()
+	e.control(typeUint128, len(b))
+	e.buf = append(e.buf, b...)
+	return nil
+}
+
+func (e *encoder) encodeUint(typ int, n uint64) {
+	size := 0
+	for x := n; x > 0; x >>= 8 {
+		size++
+	}
+	e.control(typ, size)
+	e.buf = appendUint(e.buf, n, size)
+}
+
+func (e *encoder) control(typ, size int) {
+	first := byte(typ << 5)
+	if typ > typeMap {
+		first = 0
+	}
+	var extra []byte
+	switch {
+	case size < 29:
+		first |= byte(size)
+	case size < 285:
+		first |= 29
+		extra = appendUint(extra, uint64(size-29), 1)
+	case size < 65821:
+		first |= 30
+		extra = appendUint(extra, uint64(size-285), 2)
+	default:
+		first |= 31
+		extra = appendUint(extra, uint64(size-65821), 3)
+	}
+	e.buf = append(e.buf, first)
+	if typ > typeMap {
+		e.buf = append(e.buf, byte(typ-typeMap))
+	}
+	e.buf = append(e.buf, extra...)
+}
+
+func appendUint(dst []byte, n uint64, size int) []byte {
+	for i := size - 1; i >= 0; i-- {
+		dst = append(dst, byte(n>>(i*8)))
+	}
+	return dst
+}
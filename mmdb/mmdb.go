@@ -0,0 +1,170 @@
+// Package mmdb reads and writes MaxMind DB files, the format used by the
+// GeoIP2/GeoLite2 databases and by many enrichment tools.
+package mmdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/midbel/ipaddr"
+)
+
+var (
+	ErrCorrupted = errors.New("mmdb: corrupted database")
+	ErrFamily    = errors.New("mmdb: IPv6 address in IPv4 database")
+)
+
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+const (
+	maxMetadataSize = 128 << 10
+	dataSeparator   = 16
+)
+
+type Metadata struct {
+	NodeCount    uint              `maxminddb:"node_count"`
+	RecordSize   uint              `maxminddb:"record_size"`
+	IPVersion    uint              `maxminddb:"ip_version"`
+	DatabaseType string            `maxminddb:"database_type"`
+	Languages    []string          `maxminddb:"languages"`
+	Description  map[string]string `maxminddb:"description"`
+	BuildEpoch   uint64            `maxminddb:"build_epoch"`
+	MajorVersion uint              `maxminddb:"binary_format_major_version"`
+	MinorVersion uint              `maxminddb:"binary_format_minor_version"`
+}
+
+type Reader struct {
+	Metadata Metadata
+
+	tree      []byte
+	data      decoder
+	nodeSize  uint
+	ipv4Start uint
+}
+
+func Open(file string) (*Reader, error) {
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return FromBytes(buf)
+}
+
+func FromBytes(buf []byte) (*Reader, error) {
+	start := len(buf) - maxMetadataSize
+	if start < 0 {
+		start = 0
+	}
+	x := bytes.LastIndex(buf[start:], metadataMarker)
+	if x < 0 {
+		return nil, fmt.Errorf("%w: metadata not found", ErrCorrupted)
+	}
+	x += start
+
+	var (
+		r  Reader
+		md = decoder{buf: buf[x+len(metadataMarker):]}
+	)
+	v, _, err := md.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := assign(reflect.ValueOf(&r.Metadata), v); err != nil {
+		return nil, err
+	}
+	switch r.Metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrCorrupted, r.Metadata.RecordSize)
+	}
+	if v := r.Metadata.IPVersion; v != 4 && v != 6 {
+		return nil, fmt.Errorf("%w: unsupported ip version %d", ErrCorrupted, v)
+	}
+	r.nodeSize = r.Metadata.RecordSize / 4
+	size := r.Metadata.NodeCount * r.nodeSize
+	if size+dataSeparator > uint(x) {
+		return nil, fmt.Errorf("%w: search tree larger than file", ErrCorrupted)
+	}
+	r.tree = buf[:size]
+	r.data = decoder{buf: buf[size+dataSeparator : x]}
+
+	if r.Metadata.IPVersion == 6 {
+		for i := 0; i < 96 && r.ipv4Start < r.Metadata.NodeCount; i++ {
+			r.ipv4Start = r.record(r.ipv4Start, 0)
+		}
+	}
+	return &r, nil
+}
+
+// Lookup finds the record of the given address and decodes it into v which
+// should be a pointer to a struct, a map or an interface{}. Struct fields are
+// matched on their maxminddb tag or on their name. It returns the network of
+// the record and false when the address is not in the database.
+func (r *Reader) Lookup(ip ipaddr.IP, v interface{}) (ipaddr.Net, bool, error) {
+	ptr, nw, err := r.lookup(ip)
+	if err != nil || ptr == 0 {
+		return nw, false, err
+	}
+	val, _, err := r.data.decode(ptr - r.Metadata.NodeCount - dataSeparator)
+	if err != nil {
+		return nw, false, err
+	}
+	dst := reflect.ValueOf(v)
+	if dst.Kind() != reflect.Ptr || dst.IsNil() {
+		return nw, false, fmt.Errorf("mmdb: non-nil pointer expected (got %T)", v)
+	}
+	return nw, true, assign(dst, val)
+}
+
+func (r *Reader) lookup(ip ipaddr.IP) (uint, ipaddr.Net, error) {
+	addr := ip.ToStdIP()
+	switch {
+	case addr == nil:
+		return 0, ipaddr.Net{}, fmt.Errorf("mmdb: invalid address %s", ip)
+	case len(addr) == 16 && r.Metadata.IPVersion == 4:
+		return 0, ipaddr.Net{}, fmt.Errorf("%s: %w", ip, ErrFamily)
+	}
+	var (
+		node  uint
+		depth int
+		bits  = len(addr) * 8
+	)
+	if len(addr) == 4 && r.Metadata.IPVersion == 6 {
+		node = r.ipv4Start
+	}
+	for ; depth < bits && node < r.Metadata.NodeCount; depth++ {
+		bit := addr[depth/8] >> (7 - depth%8) & 1
+		node = r.record(node, bit)
+	}
+	nw, err := ip.Mask(uint8(depth))
+	if err != nil {
+		return 0, nw, err
+	}
+	switch {
+	case node == r.Metadata.NodeCount:
+		return 0, nw, nil
+	case node < r.Metadata.NodeCount:
+		return 0, nw, fmt.Errorf("%w: search tree deeper than address", ErrCorrupted)
+	}
+	return node, nw, nil
+}
+
+func (r *Reader) record(node uint, bit byte) uint {
+	b := r.tree[node*r.nodeSize : (node+1)*r.nodeSize]
+	switch r.Metadata.RecordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		b = b[bit*4:]
+		return uint(b[0])<<24 | uint(b[1])<<16 | uint(b[2])<<8 | uint(b[3])
+	}
+}
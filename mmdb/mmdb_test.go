@@ -0,0 +1,205 @@
+package mmdb
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"runtime"
+	"testing"
+
+	"github.com/midbel/ipaddr"
+)
+
+type testRecord struct {
+	Country string   `maxminddb:"country"`
+	ASN     uint32   `maxminddb:"asn"`
+	Tags    []string `maxminddb:"tags"`
+	Ignored string   `maxminddb:"-"`
+}
+
+func TestReader(t *testing.T) {
+	for _, size := range []int{24, 28, 32} {
+		buf := makeDatabase(t, size, 4)
+		r, err := FromBytes(buf)
+		if err != nil {
+			t.Fatalf("%d: fail to open database: %s", size, err)
+		}
+		if r.Metadata.DatabaseType != "test" || r.Metadata.NodeCount != 2 {
+			t.Fatalf("%d: metadata mismatched! got %+v", size, r.Metadata)
+		}
+		data := []struct {
+			Addr    string
+			Net     string
+			Country string
+			Found   bool
+		}{
+			{Addr: "192.0.2.1", Net: "128.0.0.0/1", Country: "FR", Found: true},
+			{Addr: "100.64.0.1", Net: "64.0.0.0/2", Country: "BE", Found: true},
+			{Addr: "10.0.0.1", Net: "0.0.0.0/2"},
+		}
+		for _, d := range data {
+			var rec testRecord
+			ip, _ := ipaddr.ParseIP(d.Addr)
+			nw, ok, err := r.Lookup(ip, &rec)
+			if err != nil {
+				t.Errorf("%d/%s: unexpected error: %s", size, d.Addr, err)
+				continue
+			}
+			if ok != d.Found || nw.String() != d.Net {
+				t.Errorf("%d/%s: lookup mismatched! want %s (%t), got %s (%t)", size, d.Addr, d.Net, d.Found, nw, ok)
+			}
+			if rec.Country != d.Country {
+				t.Errorf("%d/%s: country mismatched! want %s, got %s", size, d.Addr, d.Country, rec.Country)
+			}
+			if rec.Country == "FR" && (rec.ASN != 64500 || len(rec.Tags) != 2 || rec.Tags[1] != "lan") {
+				t.Errorf("%d/%s: record mismatched! got %+v", size, d.Addr, rec)
+			}
+		}
+		ip, _ := ipaddr.ParseIP("2001:db8::1")
+		if _, _, err := r.Lookup(ip, new(testRecord)); !errors.Is(err, ErrFamily) {
+			t.Errorf("%d: expected ErrFamily, got %v", size, err)
+		}
+	}
+}
+
+func TestReaderIPv6(t *testing.T) {
+	r, err := FromBytes(makeDatabase(t, 28, 6))
+	if err != nil {
+		t.Fatalf("fail to open database: %s", err)
+	}
+	var rec map[string]interface{}
+	ip, _ := ipaddr.ParseIP("203.0.113.1")
+	nw, ok, err := r.Lookup(ip, &rec)
+	if err != nil || !ok {
+		t.Fatalf("lookup failed: %v (%t)", err, ok)
+	}
+	if nw.String() != "128.0.0.0/1" || rec["country"] != "FR" || rec["asn"] != uint64(64500) {
+		t.Errorf("lookup mismatched! got %s %v", nw, rec)
+	}
+	ip, _ = ipaddr.ParseIP("8000::1")
+	if nw, ok, err = r.Lookup(ip, &rec); err != nil || ok || nw.String() != "8000::/1" {
+		t.Errorf("lookup mismatched! got %s (%t, %v)", nw, ok, err)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	data := []struct {
+		Input []byte
+		Want  interface{}
+	}{
+		{Input: []byte{0x43, 'f', 'o', 'o'}, Want: "foo"},
+		{Input: []byte{0xa2, 0x01, 0x00}, Want: uint64(256)},
+		{Input: []byte{0xc4, 0xff, 0xff, 0xff, 0xff}, Want: uint64(1<<32 - 1)},
+		{Input: []byte{0x04, 0x01, 0xff, 0xff, 0xff, 0xfe}, Want: -2},
+		{Input: []byte{0x01, 0x07}, Want: true},
+		{Input: []byte{0x09, 0x03, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, Want: new(big.Int).Lsh(big.NewInt(1), 64)},
+		{Input: []byte{0x68, 0x3f, 0xf0, 0, 0, 0, 0, 0, 0}, Want: 1.0},
+		{Input: []byte{0x04, 0x08, 0x3f, 0x80, 0, 0}, Want: float32(1)},
+		{Input: append([]byte{0x5d, 0x03}, bytes.Repeat([]byte{'a'}, 32)...), Want: string(bytes.Repeat([]byte{'a'}, 32))},
+	}
+	for i, d := range data {
+		got, next, err := decoder{buf: d.Input}.decode(0)
+		if err != nil {
+			t.Errorf("%d: unexpected error: %s", i, err)
+			continue
+		}
+		if next != uint(len(d.Input)) {
+			t.Errorf("%d: offset mismatched! want %d, got %d", i, len(d.Input), next)
+		}
+		if b, ok := d.Want.(*big.Int); ok {
+			if g, ok := got.(*big.Int); !ok || g.Cmp(b) != 0 {
+				t.Errorf("%d: value mismatched! want %v, got %v", i, d.Want, got)
+			}
+			continue
+		}
+		if got != d.Want {
+			t.Errorf("%d: value mismatched! want %v (%[2]T), got %v (%[3]T)", i, d.Want, got)
+		}
+	}
+}
+
+func TestDecodeCorrupted(t *testing.T) {
+	data := [][]byte{
+		{0x43, 'f', 'o'},
+		{0xe1, 0x01, 'a'},
+		{0x20, 0x10},
+		{0x00, 0x00},
+		{0xa5, 0, 0, 0, 0, 0},
+		{0xff, 0xff, 0xff, 0xff},
+		{0x1f, 0x04, 0xff, 0xff, 0xff},
+	}
+	for i, b := range data {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		if _, _, err := (decoder{buf: b}).decode(0); !errors.Is(err, ErrCorrupted) {
+			t.Errorf("%d: expected ErrCorrupted, got %v", i, err)
+		}
+		runtime.ReadMemStats(&after)
+		if n := after.TotalAlloc - before.TotalAlloc; n > 1<<16 {
+			t.Errorf("%d: too many bytes allocated (%d)", i, n)
+		}
+	}
+}
+
+// makeDatabase builds a database where 128.0.0.0/1 maps to FR and 64.0.0.0/2
+// maps to BE. For IPv6 databases, the IPv4 subtree is reached through 96 zero
+// bits.
+func makeDatabase(t *testing.T, size, version int) []byte {
+	t.Helper()
+	var (
+		fr = []byte{
+			0xe3,
+			0x47, 'c', 'o', 'u', 'n', 't', 'r', 'y', 0x42, 'F', 'R',
+			0x43, 'a', 's', 'n', 0xc2, 0xfb, 0xf4,
+			0x44, 't', 'a', 'g', 's', 0x02, 0x04, 0x43, 'e', 'u', 'r',
+			0x43, 'l', 'a', 'n', 0x42, 'f', 'r',
+		}
+		be = []byte{
+			0xe1,
+			0x20, 0x01, // pointer to "country"
+			0x42, 'B', 'E',
+		}
+		data   = append(fr, be...)
+		prefix = 0
+	)
+	if version == 6 {
+		prefix = 96
+	}
+	var (
+		count = uint(prefix + 2)
+		empty = count
+		tree  []byte
+	)
+	for i := 0; i < prefix; i++ {
+		tree = append(tree, makeNode(size, uint(i+1), empty)...)
+	}
+	tree = append(tree, makeNode(size, uint(prefix+1), count+dataSeparator)...)
+	tree = append(tree, makeNode(size, empty, count+dataSeparator+uint(len(fr)))...)
+
+	var buf bytes.Buffer
+	buf.Write(tree)
+	buf.Write(make([]byte, dataSeparator))
+	buf.Write(data)
+	buf.Write(metadataMarker)
+	buf.Write([]byte{0xe4})
+	buf.Write([]byte{0x4a, 'n', 'o', 'd', 'e', '_', 'c', 'o', 'u', 'n', 't', 0xc1, byte(count)})
+	buf.Write([]byte{0x4b, 'r', 'e', 'c', 'o', 'r', 'd', '_', 's', 'i', 'z', 'e', 0xa1, byte(size)})
+	buf.Write([]byte{0x4a, 'i', 'p', '_', 'v', 'e', 'r', 's', 'i', 'o', 'n', 0xa1, byte(version)})
+	buf.Write([]byte{0x4d, 'd', 'a', 't', 'a', 'b', 'a', 's', 'e', '_', 't', 'y', 'p', 'e', 0x44, 't', 'e', 's', 't'})
+	return buf.Bytes()
+}
+
+func makeNode(size int, left, right uint) []byte {
+	switch size {
+	case 24:
+		return []byte{byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right)}
+	case 28:
+		mid := byte(left>>20)&0xf0 | byte(right>>24)&0x0f
+		return []byte{byte(left >> 16), byte(left >> 8), byte(left), mid, byte(right >> 16), byte(right >> 8), byte(right)}
+	default:
+		return []byte{
+			byte(left >> 24), byte(left >> 16), byte(left >> 8), byte(left),
+			byte(right >> 24), byte(right >> 16), byte(right >> 8), byte(right),
+		}
+	}
+}
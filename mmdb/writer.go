@@ -0,0 +1,282 @@
+package mmdb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/midbel/ipaddr"
+)
+
+var (
+	aliasMapped = mustParseNet("::ffff:0:0/96")
+	alias6to4   = mustParseNet("2002::/16")
+)
+
+type entry struct {
+	net  ipaddr.Net
+	bits []byte
+	data string
+}
+
+type wnode struct {
+	child [2]*wnode
+	data  string
+	set   bool
+}
+
+// Writer builds a MaxMind DB from a list of networks. When networks overlap,
+// the most specific one wins for the addresses it covers. In IPv6 databases,
+// the IPv4 networks are stored under ::/96 and the IPv4-mapped (::ffff:0:0/96)
+// and 6to4 (2002::/16) ranges are aliased to them.
+type Writer struct {
+	DatabaseType string
+	Description  map[string]string
+	Languages    []string
+	IPVersion    int
+	BuildTime    time.Time
+
+	entries []entry
+}
+
+func NewWriter(dbtype string) *Writer {
+	return &Writer{
+		DatabaseType: dbtype,
+		IPVersion:    6,
+	}
+}
+
+func (w *Writer) Insert(nw ipaddr.Net, value interface{}) error {
+	addr := nw.Address()
+	if addr.Is6() && w.IPVersion == 4 {
+		return fmt.Errorf("%s: %w", nw, ErrFamily)
+	}
+	var e encoder
+	if err := e.encode(value); err != nil {
+		return fmt.Errorf("%s: %w", nw, err)
+	}
+	var (
+		raw  = addr.ToStdIP()
+		bits = make([]byte, 0, nw.Size()+96)
+	)
+	if len(raw) == 4 && w.IPVersion != 4 {
+		bits = append(bits, make([]byte, 96)...)
+	}
+	for i := 0; i < nw.Size(); i++ {
+		bits = append(bits, raw[i/8]>>(7-i%8)&1)
+	}
+	w.entries = append(w.entries, entry{
+		net:  nw,
+		bits: bits,
+		data: string(e.buf),
+	})
+	return nil
+}
+
+func (w *Writer) WriteTo(out io.Writer) (int64, error) {
+	if w.IPVersion != 4 && w.IPVersion != 6 {
+		return 0, fmt.Errorf("mmdb: unsupported ip version %d", w.IPVersion)
+	}
+	root := w.build()
+
+	var (
+		index = make(map[*wnode]uint)
+		nodes []*wnode
+	)
+	for queue := []*wnode{root}; len(queue) > 0; queue = queue[1:] {
+		n := queue[0]
+		if _, ok := index[n]; ok {
+			continue
+		}
+		index[n] = uint(len(nodes))
+		nodes = append(nodes, n)
+		for _, c := range n.child {
+			if c != nil && !c.set {
+				queue = append(queue, c)
+			}
+		}
+	}
+
+	var (
+		count   = uint(len(nodes))
+		data    bytes.Buffer
+		offsets = make(map[string]uint)
+		records = make([]uint, 0, 2*count)
+		largest uint
+	)
+	for _, n := range nodes {
+		for _, c := range n.child {
+			var rec uint
+			switch {
+			case c == nil:
+				rec = count
+			case c.set:
+				off, ok := offsets[c.data]
+				if !ok {
+					off = uint(data.Len())
+					offsets[c.data] = off
+					data.WriteString(c.data)
+				}
+				rec = count + dataSeparator + off
+			default:
+				rec = index[c]
+			}
+			if rec > largest {
+				largest = rec
+			}
+			records = append(records, rec)
+		}
+	}
+	var size uint
+	switch {
+	case largest < 1<<24:
+		size = 24
+	case largest < 1<<28:
+		size = 28
+	case uint64(largest) < 1<<32:
+		size = 32
+	default:
+		return 0, fmt.Errorf("mmdb: database too large")
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < len(records); i += 2 {
+		buf.Write(appendNode(nil, size, records[i], records[i+1]))
+	}
+	buf.Write(make([]byte, dataSeparator))
+	buf.Write(data.Bytes())
+	buf.Write(metadataMarker)
+
+	md, err := w.metadata(count, size)
+	if err != nil {
+		return 0, err
+	}
+	buf.Write(md)
+	return buf.WriteTo(out)
+}
+
+func (w *Writer) build() *wnode {
+	entries := make([]entry, len(w.entries))
+	copy(entries, w.entries)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return len(entries[i].bits) < len(entries[j].bits)
+	})
+	root := &wnode{}
+	for _, e := range entries {
+		curr := root
+		for _, b := range e.bits {
+			curr.split()
+			if curr.child[b] == nil {
+				curr.child[b] = &wnode{}
+			}
+			curr = curr.child[b]
+		}
+		curr.child = [2]*wnode{}
+		curr.data, curr.set = e.data, true
+	}
+	root.split()
+	if w.IPVersion == 6 {
+		w.alias(root)
+	}
+	return root
+}
+
+func (w *Writer) alias(root *wnode) {
+	v4 := root
+	for i := 0; i < 96 && v4 != nil; i++ {
+		v4 = v4.child[0]
+	}
+	if v4 == nil {
+		return
+	}
+	for _, nw := range []ipaddr.Net{aliasMapped, alias6to4} {
+		var (
+			raw  = nw.Address().ToStdIP()
+			curr = root
+			last = nw.Size() - 1
+		)
+		for i := 0; i < last; i++ {
+			b := raw[i/8] >> (7 - i%8) & 1
+			curr.split()
+			if curr.child[b] == nil {
+				curr.child[b] = &wnode{}
+			}
+			curr = curr.child[b]
+		}
+		curr.split()
+		b := raw[last/8] >> (7 - last%8) & 1
+		if c := curr.child[b]; c == nil || c.set {
+			curr.child[b] = v4
+		}
+	}
+}
+
+// split pushes the data of a node down to its children so that a more
+// specific network can be inserted below it.
+func (n *wnode) split() {
+	if !n.set {
+		return
+	}
+	for i := range n.child {
+		n.child[i] = &wnode{data: n.data, set: true}
+	}
+	n.data, n.set = "", false
+}
+
+func (w *Writer) metadata(count, size uint) ([]byte, error) {
+	when := w.BuildTime
+	if when.IsZero() {
+		when = time.Now()
+	}
+	var (
+		desc  = w.Description
+		langs = w.Languages
+	)
+	if desc == nil {
+		desc = make(map[string]string)
+	}
+	if langs == nil {
+		langs = []string{}
+	}
+	md := map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(when.Unix()),
+		"database_type":               w.DatabaseType,
+		"description":                 desc,
+		"ip_version":                  uint16(w.IPVersion),
+		"languages":                   langs,
+		"node_count":                  uint32(count),
+		"record_size":                 uint16(size),
+	}
+	var e encoder
+	if err := e.encode(md); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+func appendNode(dst []byte, size, left, right uint) []byte {
+	switch size {
+	case 24:
+		dst = appendUint(dst, uint64(left), 3)
+		return appendUint(dst, uint64(right), 3)
+	case 28:
+		dst = appendUint(dst, uint64(left), 3)
+		dst = append(dst, byte(left>>20)&0xf0|byte(right>>24)&0x0f)
+		return appendUint(dst, uint64(right), 3)
+	default:
+		dst = appendUint(dst, uint64(left), 4)
+		return appendUint(dst, uint64(right), 4)
+	}
+}
+
+func mustParseNet(str string) ipaddr.Net {
+	nw, err := ipaddr.ParseNet(str)
+	if err != nil {
+		panic(err)
+	}
+	return nw
+}
@@ -0,0 +1,133 @@
+package mmdb
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/midbel/ipaddr"
+)
+
+type site struct {
+	Name  string   `maxminddb:"name"`
+	Team  string   `maxminddb:"team"`
+	VLAN  uint16   `maxminddb:"vlan"`
+	Tags  []string `maxminddb:"tags"`
+	Local bool     `maxminddb:"local"`
+}
+
+func TestWriter(t *testing.T) {
+	w := NewWriter("internal-sites")
+	w.Description = map[string]string{"en": "sites"}
+	w.Languages = []string{"en"}
+	w.BuildTime = time.Unix(1700000000, 0)
+
+	entries := []struct {
+		Net  string
+		Site site
+	}{
+		{Net: "10.0.0.0/8", Site: site{Name: "corp", Team: "netops", VLAN: 1}},
+		{Net: "10.1.0.0/16", Site: site{Name: "paris", Team: "netops", VLAN: 100, Tags: []string{"eu"}, Local: true}},
+		{Net: "10.2.0.0/16", Site: site{Name: "paris", Team: "netops", VLAN: 100, Tags: []string{"eu"}, Local: true}},
+		{Net: "10.1.2.0/24", Site: site{Name: "paris-lab", Team: "research", VLAN: 4000}},
+		{Net: "2001:db8::/32", Site: site{Name: "v6", Team: "netops", VLAN: 6}},
+		{Net: "2001:db8:1::/48", Site: site{Name: "v6-lab", Team: "research", VLAN: 66}},
+	}
+	for _, e := range entries {
+		nw, err := ipaddr.ParseNet(e.Net)
+		if err != nil {
+			t.Fatalf("%s: fail to parse: %s", e.Net, err)
+		}
+		if err := w.Insert(nw, e.Site); err != nil {
+			t.Fatalf("%s: fail to insert: %s", e.Net, err)
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf); err != nil {
+		t.Fatalf("fail to write database: %s", err)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("paris-lab")); n != 1 {
+		t.Errorf("data section not deduplicated: paris-lab found %d times", n)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte{0x45, 'p', 'a', 'r', 'i', 's'}); n != 1 {
+		t.Errorf("data section not deduplicated: paris found %d times", n)
+	}
+
+	r, err := FromBytes(buf.Bytes())
+	if err != nil {
+		t.Fatalf("fail to read database: %s", err)
+	}
+	md := r.Metadata
+	if md.DatabaseType != "internal-sites" || md.IPVersion != 6 || md.BuildEpoch != 1700000000 || md.Description["en"] != "sites" {
+		t.Errorf("metadata mismatched! got %+v", md)
+	}
+	data := []struct {
+		Addr string
+		Net  string
+		Name string
+	}{
+		{Addr: "10.0.0.1", Net: "10.0.0.0/16", Name: "corp"},
+		{Addr: "10.255.0.1", Net: "10.128.0.0/9", Name: "corp"},
+		{Addr: "10.1.1.1", Net: "10.1.0.0/23", Name: "paris"},
+		{Addr: "10.1.2.1", Net: "10.1.2.0/24", Name: "paris-lab"},
+		{Addr: "10.2.0.1", Net: "10.2.0.0/16", Name: "paris"},
+		{Addr: "2001:db8::1", Net: "2001:db8::/48", Name: "v6"},
+		{Addr: "2001:db8:1::1", Net: "2001:db8:1::/48", Name: "v6-lab"},
+		{Addr: "::ffff:10.1.2.3", Net: "::ffff:a01:200/120", Name: "paris-lab"},
+		{Addr: "2002:a01:203::1", Net: "2002:a01:200::/40", Name: "paris-lab"},
+		{Addr: "192.0.2.1", Net: "128.0.0.0/1"},
+		{Addr: "2001:db9::1", Net: "2001:db9::/32"},
+	}
+	for _, d := range data {
+		var s site
+		ip, _ := ipaddr.ParseIP(d.Addr)
+		nw, ok, err := r.Lookup(ip, &s)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", d.Addr, err)
+			continue
+		}
+		if ok != (d.Name != "") || s.Name != d.Name {
+			t.Errorf("%s: record mismatched! want %q, got %q (%t)", d.Addr, d.Name, s.Name, ok)
+		}
+		if nw.String() != d.Net {
+			t.Errorf("%s: network mismatched! want %s, got %s", d.Addr, d.Net, nw)
+		}
+		if s.Name == "paris" && (s.VLAN != 100 || !s.Local || len(s.Tags) != 1) {
+			t.Errorf("%s: record mismatched! got %+v", d.Addr, s)
+		}
+	}
+}
+
+func TestWriterIPv4(t *testing.T) {
+	w := NewWriter("test")
+	w.IPVersion = 4
+
+	nw, _ := ipaddr.ParseNet("192.0.2.0/24")
+	if err := w.Insert(nw, map[string]interface{}{"asn": uint32(64500), "ratio": 0.5, "delta": -2}); err != nil {
+		t.Fatalf("fail to insert: %s", err)
+	}
+	nw, _ = ipaddr.ParseNet("2001:db8::/32")
+	if err := w.Insert(nw, "v6"); !errors.Is(err, ErrFamily) {
+		t.Errorf("expected ErrFamily, got %v", err)
+	}
+	if err := w.Insert(nw, nil); err == nil {
+		t.Errorf("expected error when inserting nil value")
+	}
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf); err != nil {
+		t.Fatalf("fail to write database: %s", err)
+	}
+	r, err := FromBytes(buf.Bytes())
+	if err != nil {
+		t.Fatalf("fail to read database: %s", err)
+	}
+	var rec map[string]interface{}
+	ip, _ := ipaddr.ParseIP("192.0.2.42")
+	if nw, ok, err := r.Lookup(ip, &rec); err != nil || !ok || nw.String() != "192.0.2.0/24" {
+		t.Fatalf("lookup failed: %s (%t, %v)", nw, ok, err)
+	}
+	if rec["asn"] != uint64(64500) || rec["ratio"] != 0.5 || rec["delta"] != -2 {
+		t.Errorf("record mismatched! got %v", rec)
+	}
+}
@@ -0,0 +1,506 @@
+// Package mrt reads RIB dumps stored in the MRT format (RFC 6396) as produced
+// by route collectors. Only the TABLE_DUMP_V2 records are decoded, other
+// records are skipped.
+package mrt
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/midbel/ipaddr"
+)
+
+var (
+	ErrTruncated = errors.New("mrt: truncated record")
+	ErrPeer      = errors.New("mrt: unknown peer")
+)
+
+const typeTableDumpV2 = 13
+
+const (
+	subtypePeerIndex  = 1
+	subtypeRIBv4      = 2
+	subtypeRIBv4Multi = 3
+	subtypeRIBv6      = 4
+	subtypeRIBv6Multi = 5
+)
+
+const (
+	peerTypeIPv6     = 0x1
+	peerTypeAS4      = 0x2
+	attrFlagExtended = 0x10
+	attrOrigin       = 1
+	attrASPath       = 2
+	attrNextHop      = 3
+	attrMPReach      = 14
+	segmentSet       = 1
+	segmentSequence  = 2
+)
+
+const (
+	headerLen    = 12
+	maxRecordLen = 1 << 24
+	magicGzip    = "\x1f\x8b"
+	magicBzip2   = "BZh"
+)
+
+type Origin uint8
+
+const (
+	OriginIGP Origin = iota
+	OriginEGP
+	OriginIncomplete
+)
+
+func (o Origin) String() string {
+	switch o {
+	case OriginIGP:
+		return "IGP"
+	case OriginEGP:
+		return "EGP"
+	case OriginIncomplete:
+		return "INCOMPLETE"
+	default:
+		return "<unknown>"
+	}
+}
+
+type Segment struct {
+	Set  bool
+	ASNs []uint32
+}
+
+type ASPath []Segment
+
+func (p ASPath) Origin() (uint32, bool) {
+	if len(p) == 0 {
+		return 0, false
+	}
+	last := p[len(p)-1]
+	if last.Set || len(last.ASNs) == 0 {
+		return 0, false
+	}
+	return last.ASNs[len(last.ASNs)-1], true
+}
+
+func (p ASPath) String() string {
+	var str strings.Builder
+	for i, s := range p {
+		if i > 0 {
+			str.WriteByte(' ')
+		}
+		sep := " "
+		if s.Set {
+			str.WriteByte('{')
+			sep = ","
+		}
+		for j, a := range s.ASNs {
+			if j > 0 {
+				str.WriteString(sep)
+			}
+			str.WriteString(strconv.FormatUint(uint64(a), 10))
+		}
+		if s.Set {
+			str.WriteByte('}')
+		}
+	}
+	return str.String()
+}
+
+type Peer struct {
+	BGPID ipaddr.IP
+	Addr  ipaddr.IP
+	ASN   uint32
+}
+
+type Entry struct {
+	Peer       Peer
+	Originated time.Time
+	Origin     Origin
+	ASPath     ASPath
+	NextHop    ipaddr.IP
+}
+
+type RIB struct {
+	Sequence  uint32
+	Prefix    ipaddr.Net
+	Multicast bool
+	Entries   []Entry
+}
+
+type Reader struct {
+	inner     *bufio.Reader
+	closer    io.Closer
+	Collector ipaddr.IP
+	View      string
+	peers     []Peer
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	var (
+		rs     = bufio.NewReader(r)
+		closer io.Closer
+	)
+	magic, _ := rs.Peek(len(magicBzip2))
+	switch {
+	case bytes.HasPrefix(magic, []byte(magicGzip)):
+		z, err := gzip.NewReader(rs)
+		if err != nil {
+			return nil, err
+		}
+		rs, closer = bufio.NewReader(z), z
+	case bytes.HasPrefix(magic, []byte(magicBzip2)):
+		rs = bufio.NewReader(bzip2.NewReader(rs))
+	}
+	return &Reader{inner: rs, closer: closer}, nil
+}
+
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+func (r *Reader) Peers() []Peer {
+	return r.peers
+}
+
+func (r *Reader) Next() (*RIB, error) {
+	for {
+		typ, sub, msg, err := r.readRecord()
+		if err != nil {
+			return nil, err
+		}
+		if typ != typeTableDumpV2 {
+			continue
+		}
+		switch sub {
+		case subtypePeerIndex:
+			if err := r.decodePeers(msg); err != nil {
+				return nil, err
+			}
+		case subtypeRIBv4, subtypeRIBv4Multi:
+			return r.decodeRIB(msg, false, sub == subtypeRIBv4Multi)
+		case subtypeRIBv6, subtypeRIBv6Multi:
+			return r.decodeRIB(msg, true, sub == subtypeRIBv6Multi)
+		}
+	}
+}
+
+func (r *Reader) readRecord() (uint16, uint16, []byte, error) {
+	var hdr [headerLen]byte
+	if _, err := io.ReadFull(r.inner, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = ErrTruncated
+		}
+		return 0, 0, nil, err
+	}
+	var (
+		typ  = binary.BigEndian.Uint16(hdr[4:])
+		sub  = binary.BigEndian.Uint16(hdr[6:])
+		size = binary.BigEndian.Uint32(hdr[8:])
+	)
+	if size > maxRecordLen {
+		return 0, 0, nil, fmt.Errorf("mrt: record too large (%d bytes)", size)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r.inner, msg); err != nil {
+		return 0, 0, nil, ErrTruncated
+	}
+	return typ, sub, msg, nil
+}
+
+func (r *Reader) decodePeers(msg []byte) error {
+	var (
+		b   = buffer(msg)
+		err error
+	)
+	if r.Collector, err = b.ip(false); err != nil {
+		return err
+	}
+	n, err := b.uint16()
+	if err != nil {
+		return err
+	}
+	view, err := b.next(int(n))
+	if err != nil {
+		return err
+	}
+	r.View = string(view)
+	if n, err = b.uint16(); err != nil {
+		return err
+	}
+	r.peers = make([]Peer, 0, n)
+	for i := 0; i < int(n); i++ {
+		var p Peer
+		typ, err := b.uint8()
+		if err != nil {
+			return err
+		}
+		if p.BGPID, err = b.ip(false); err != nil {
+			return err
+		}
+		if p.Addr, err = b.ip(typ&peerTypeIPv6 != 0); err != nil {
+			return err
+		}
+		if typ&peerTypeAS4 != 0 {
+			p.ASN, err = b.uint32()
+		} else {
+			var asn uint16
+			asn, err = b.uint16()
+			p.ASN = uint32(asn)
+		}
+		if err != nil {
+			return err
+		}
+		r.peers = append(r.peers, p)
+	}
+	return nil
+}
+
+func (r *Reader) decodeRIB(msg []byte, v6, multicast bool) (*RIB, error) {
+	var (
+		b   = buffer(msg)
+		rib = RIB{Multicast: multicast}
+		err error
+	)
+	if rib.Sequence, err = b.uint32(); err != nil {
+		return nil, err
+	}
+	if rib.Prefix, err = b.prefix(v6); err != nil {
+		return nil, err
+	}
+	n, err := b.uint16()
+	if err != nil {
+		return nil, err
+	}
+	rib.Entries = make([]Entry, 0, n)
+	for i := 0; i < int(n); i++ {
+		e, err := r.decodeEntry(&b, v6)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rib.Prefix, err)
+		}
+		rib.Entries = append(rib.Entries, e)
+	}
+	return &rib, nil
+}
+
+func (r *Reader) decodeEntry(b *buffer, v6 bool) (Entry, error) {
+	var e Entry
+	index, err := b.uint16()
+	if err != nil {
+		return e, err
+	}
+	if int(index) >= len(r.peers) {
+		return e, fmt.Errorf("%w: index %d", ErrPeer, index)
+	}
+	e.Peer = r.peers[index]
+	when, err := b.uint32()
+	if err != nil {
+		return e, err
+	}
+	e.Originated = time.Unix(int64(when), 0).UTC()
+	size, err := b.uint16()
+	if err != nil {
+		return e, err
+	}
+	attrs, err := b.next(int(size))
+	if err != nil {
+		return e, err
+	}
+	return e, decodeAttributes(&e, buffer(attrs), v6)
+}
+
+func decodeAttributes(e *Entry, b buffer, v6 bool) error {
+	for len(b) > 0 {
+		flags, err := b.uint8()
+		if err != nil {
+			return err
+		}
+		typ, err := b.uint8()
+		if err != nil {
+			return err
+		}
+		var size int
+		if flags&attrFlagExtended != 0 {
+			n, err := b.uint16()
+			size = int(n)
+			if err != nil {
+				return err
+			}
+		} else {
+			n, err := b.uint8()
+			size = int(n)
+			if err != nil {
+				return err
+			}
+		}
+		value, err := b.next(size)
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case attrOrigin:
+			if len(value) != 1 {
+				return ErrTruncated
+			}
+			e.Origin = Origin(value[0])
+		case attrASPath:
+			if e.ASPath, err = decodeASPath(buffer(value)); err != nil {
+				return err
+			}
+		case attrNextHop:
+			b := buffer(value)
+			if e.NextHop, err = b.ip(false); err != nil {
+				return err
+			}
+		case attrMPReach:
+			if e.NextHop, err = decodeMPNextHop(value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func decodeASPath(b buffer) (ASPath, error) {
+	var path ASPath
+	for len(b) > 0 {
+		typ, err := b.uint8()
+		if err != nil {
+			return nil, err
+		}
+		n, err := b.uint8()
+		if err != nil {
+			return nil, err
+		}
+		s := Segment{
+			Set:  typ == segmentSet,
+			ASNs: make([]uint32, 0, n),
+		}
+		for i := 0; i < int(n); i++ {
+			asn, err := b.uint32()
+			if err != nil {
+				return nil, err
+			}
+			s.ASNs = append(s.ASNs, asn)
+		}
+		path = append(path, s)
+	}
+	return path, nil
+}
+
+// decodeMPNextHop extracts the next hop of a MP_REACH_NLRI attribute. RFC 6396
+// only keeps the length and address fields but some implementations dump the
+// attribute with its AFI and SAFI.
+func decodeMPNextHop(value []byte) (ipaddr.IP, error) {
+	b := buffer(value)
+	if len(b) > 0 && int(b[0]) != len(b)-1 {
+		if _, err := b.next(3); err != nil {
+			return ipaddr.Zero, err
+		}
+	}
+	n, err := b.uint8()
+	if err != nil {
+		return ipaddr.Zero, err
+	}
+	switch n {
+	case 4:
+		return b.ip(false)
+	case 16, 32:
+		return b.ip(true)
+	default:
+		return ipaddr.Zero, fmt.Errorf("mrt: invalid next hop length %d", n)
+	}
+}
+
+type buffer []byte
+
+func (b *buffer) next(n int) ([]byte, error) {
+	if n > len(*b) {
+		return nil, ErrTruncated
+	}
+	v := (*b)[:n]
+	*b = (*b)[n:]
+	return v, nil
+}
+
+func (b *buffer) uint8() (uint8, error) {
+	v, err := b.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return v[0], nil
+}
+
+func (b *buffer) uint16() (uint16, error) {
+	v, err := b.next(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(v), nil
+}
+
+func (b *buffer) uint32() (uint32, error) {
+	v, err := b.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(v), nil
+}
+
+func (b *buffer) ip(v6 bool) (ipaddr.IP, error) {
+	size := 4
+	if v6 {
+		size = 16
+	}
+	v, err := b.next(size)
+	if err != nil {
+		return ipaddr.Zero, err
+	}
+	return makeIP(v), nil
+}
+
+func (b *buffer) prefix(v6 bool) (ipaddr.Net, error) {
+	size, err := b.uint8()
+	if err != nil {
+		return ipaddr.Net{}, err
+	}
+	var (
+		addr = make([]byte, 4)
+		bits = 32
+	)
+	if v6 {
+		addr, bits = make([]byte, 16), 128
+	}
+	if int(size) > bits {
+		return ipaddr.Net{}, fmt.Errorf("mrt: invalid prefix length %d", size)
+	}
+	v, err := b.next((int(size) + 7) / 8)
+	if err != nil {
+		return ipaddr.Net{}, err
+	}
+	copy(addr, v)
+	return makeIP(addr).Mask(size)
+}
+
+func makeIP(b []byte) ipaddr.IP {
+	if len(b) == 4 {
+		return ipaddr.IPv4(b[0], b[1], b[2], b[3])
+	}
+	var g [8]uint16
+	for i := range g {
+		g[i] = binary.BigEndian.Uint16(b[i*2:])
+	}
+	return ipaddr.IPv6(g[0], g[1], g[2], g[3], g[4], g[5], g[6], g[7])
+}
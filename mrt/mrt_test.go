@@ -0,0 +1,135 @@
+package mrt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	dump := makeDump()
+
+	var gz bytes.Buffer
+	z := gzip.NewWriter(&gz)
+	z.Write(dump)
+	z.Close()
+
+	// testdata/dump.mrt.bz2 holds the output of makeDump compressed with
+	// bzip2 since the standard library can only decompress it.
+	bz, err := os.ReadFile("testdata/dump.mrt.bz2")
+	if err != nil {
+		t.Fatalf("fail to read bzip2 dump: %s", err)
+	}
+	for _, input := range [][]byte{dump, gz.Bytes(), bz} {
+		r, err := NewReader(bytes.NewReader(input))
+		if err != nil {
+			t.Fatalf("fail to create reader: %s", err)
+		}
+		var ribs []*RIB
+		for {
+			rib, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			ribs = append(ribs, rib)
+		}
+		if r.View != "test" || len(r.Peers()) != 2 {
+			t.Fatalf("peer index mismatched! got view %q with %d peers", r.View, len(r.Peers()))
+		}
+		if len(ribs) != 2 {
+			t.Fatalf("ribs count mismatched! want 2, got %d", len(ribs))
+		}
+		checkRIB(t, ribs[0], "192.0.2.0/24", "64500 64501 {64502,64503}", "10.0.0.1", 64500)
+		checkRIB(t, ribs[1], "2001:db8::/32", "64510 64511", "2001:db8::1", 64510)
+	}
+}
+
+func checkRIB(t *testing.T, rib *RIB, prefix, path, nexthop string, peer uint32) {
+	t.Helper()
+	if rib.Prefix.String() != prefix {
+		t.Errorf("prefix mismatched! want %s, got %s", prefix, rib.Prefix)
+	}
+	if len(rib.Entries) != 1 {
+		t.Fatalf("%s: entries count mismatched! want 1, got %d", prefix, len(rib.Entries))
+	}
+	e := rib.Entries[0]
+	if e.ASPath.String() != path {
+		t.Errorf("%s: as path mismatched! want %s, got %s", prefix, path, e.ASPath)
+	}
+	if e.NextHop.String() != nexthop {
+		t.Errorf("%s: next hop mismatched! want %s, got %s", prefix, nexthop, e.NextHop)
+	}
+	if e.Peer.ASN != peer {
+		t.Errorf("%s: peer mismatched! want %d, got %d", prefix, peer, e.Peer.ASN)
+	}
+	if e.Origin != OriginIGP {
+		t.Errorf("%s: origin mismatched! want %s, got %s", prefix, OriginIGP, e.Origin)
+	}
+}
+
+func makeDump() []byte {
+	var (
+		buf   bytes.Buffer
+		peers bytes.Buffer
+	)
+	peers.Write([]byte{10, 0, 0, 254})
+	binary.Write(&peers, binary.BigEndian, uint16(4))
+	peers.WriteString("test")
+	binary.Write(&peers, binary.BigEndian, uint16(2))
+	peers.Write([]byte{peerTypeAS4, 10, 0, 0, 1, 10, 0, 0, 1})
+	binary.Write(&peers, binary.BigEndian, uint32(64500))
+	peers.Write([]byte{peerTypeAS4 | peerTypeIPv6, 10, 0, 0, 2})
+	peers.Write([]byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1})
+	binary.Write(&peers, binary.BigEndian, uint32(64510))
+	writeRecord(&buf, typeTableDumpV2, subtypePeerIndex, peers.Bytes())
+
+	// unrelated records which should be skipped: BGP4MP_STATE_CHANGE and
+	// BGP4MP_ET records whose subtypes overlap those of TABLE_DUMP_V2
+	writeRecord(&buf, typeBGP4MP, 0, []byte{0xff, 0xff})
+	writeRecord(&buf, typeBGP4MPET, subtypePeerIndex, []byte{0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff})
+	writeRecord(&buf, typeBGP4MPET, subtypeRIBv6, []byte{0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff})
+
+	attrs := []byte{0x40, attrOrigin, 1, 0}
+	attrs = append(attrs, 0x50, attrASPath, 0, 20)
+	attrs = append(attrs, segmentSequence, 2, 0, 0, 0xfb, 0xf4, 0, 0, 0xfb, 0xf5)
+	attrs = append(attrs, segmentSet, 2, 0, 0, 0xfb, 0xf6, 0, 0, 0xfb, 0xf7)
+	attrs = append(attrs, 0x40, attrNextHop, 4, 10, 0, 0, 1)
+	writeRecord(&buf, typeTableDumpV2, subtypeRIBv4, rib([]byte{24, 192, 0, 2}, 0, attrs))
+
+	attrs = []byte{0x40, attrOrigin, 1, 0}
+	attrs = append(attrs, 0x40, attrASPath, 10, segmentSequence, 2, 0, 0, 0xfb, 0xfe, 0, 0, 0xfb, 0xff)
+	attrs = append(attrs, 0x80, attrMPReach, 17, 16, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1)
+	writeRecord(&buf, typeTableDumpV2, subtypeRIBv6, rib([]byte{32, 0x20, 0x01, 0x0d, 0xb8}, 1, attrs))
+	return buf.Bytes()
+}
+
+func rib(prefix []byte, peer uint16, attrs []byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint32(1))
+	buf.Write(prefix)
+	binary.Write(&buf, binary.BigEndian, uint16(1))
+	binary.Write(&buf, binary.BigEndian, peer)
+	binary.Write(&buf, binary.BigEndian, uint32(1700000000))
+	binary.Write(&buf, binary.BigEndian, uint16(len(attrs)))
+	buf.Write(attrs)
+	return buf.Bytes()
+}
+
+const (
+	typeBGP4MP   = 16
+	typeBGP4MPET = 17
+)
+
+func writeRecord(w io.Writer, typ, sub uint16, msg []byte) {
+	binary.Write(w, binary.BigEndian, uint32(1700000000))
+	binary.Write(w, binary.BigEndian, typ)
+	binary.Write(w, binary.BigEndian, sub)
+	binary.Write(w, binary.BigEndian, uint32(len(msg)))
+	w.Write(msg)
+}
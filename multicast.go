@@ -0,0 +1,195 @@
+package ipaddr
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+var ErrMulticast = errors.New("not a multicast address")
+
+type Scope uint8
+
+const (
+	ScopeReserved     Scope = 0x0
+	ScopeInterface    Scope = 0x1
+	ScopeLink         Scope = 0x2
+	ScopeRealm        Scope = 0x3
+	ScopeAdmin        Scope = 0x4
+	ScopeSite         Scope = 0x5
+	ScopeOrganization Scope = 0x8
+	ScopeGlobal       Scope = 0xe
+)
+
+func (s Scope) String() string {
+	switch s {
+	case ScopeReserved:
+		return "reserved"
+	case ScopeInterface:
+		return "interface-local"
+	case ScopeLink:
+		return "link-local"
+	case ScopeRealm:
+		return "realm-local"
+	case ScopeAdmin:
+		return "admin-local"
+	case ScopeSite:
+		return "site-local"
+	case ScopeOrganization:
+		return "organization-local"
+	case ScopeGlobal:
+		return "global"
+	default:
+		return "unassigned"
+	}
+}
+
+type MulticastFlags uint8
+
+const (
+	FlagTransient  MulticastFlags = 1 << iota // T: not permanently assigned
+	FlagPrefix                                // P: RFC 3306 unicast-prefix-based
+	FlagRendezvous                            // R: RFC 3956 embedded RP
+)
+
+func (f MulticastFlags) Transient() bool {
+	return f&FlagTransient != 0
+}
+
+func (f MulticastFlags) Prefix() bool {
+	return f&FlagPrefix != 0
+}
+
+func (f MulticastFlags) Rendezvous() bool {
+	return f&FlagRendezvous != 0
+}
+
+func (f MulticastFlags) String() string {
+	str := []byte("0RPT")
+	for i, j := 0, FlagRendezvous; j > 0; i, j = i+1, j>>1 {
+		if f&j == 0 {
+			str[i+1] = '-'
+		}
+	}
+	return string(str[1:])
+}
+
+func (i IP) MulticastScope() Scope {
+	if !i.IsMulticast() {
+		return ScopeReserved
+	}
+	if i.zone == z6 {
+		return Scope(i.set.high>>48) & 0xf
+	}
+	var (
+		fst = byte(i.set.low >> 24)
+		snd = byte(i.set.low >> 16)
+		trd = byte(i.set.low >> 8)
+	)
+	switch {
+	case fst == 224 && snd == 0 && trd == 0:
+		return ScopeLink
+	case fst == 239 && snd == 255:
+		return ScopeSite
+	case fst == 239 && snd>>2 == 0b1100_00:
+		return ScopeOrganization
+	case fst == 239:
+		return ScopeAdmin
+	default:
+		return ScopeGlobal
+	}
+}
+
+func (i IP) MulticastFlags() MulticastFlags {
+	if i.zone != z6 || !i.IsMulticast() {
+		return 0
+	}
+	return MulticastFlags(i.set.high>>52) & 0x7
+}
+
+func MulticastPrefix(nw Net, scope Scope, group uint32) (IP, error) {
+	if nw.ip.zone != z6 {
+		return Zero, fmt.Errorf("%s: IPv6 network expected: %w", nw, ErrInvalid)
+	}
+	plen := nw.mask.ones()
+	if plen > netmask64 {
+		return Zero, fmt.Errorf("%s: prefix longer than %d bits: %w", nw, netmask64, ErrInvalid)
+	}
+	flags := FlagPrefix | FlagTransient
+	return multicast6(flags, scope, uint64(plen)<<32, nw.ip.set.high, group), nil
+}
+
+func MulticastRP(rp IP, plen uint8, scope Scope, group uint32) (IP, error) {
+	if rp.zone != z6 {
+		return Zero, fmt.Errorf("%s: IPv6 address expected: %w", rp, ErrInvalid)
+	}
+	if plen == 0 || plen > netmask64 {
+		return Zero, fmt.Errorf("%s: invalid prefix length %d: %w", rp, plen, ErrInvalid)
+	}
+	riid := rp.set.low & 0xf
+	if rp != rpAddress(rp.set.high, plen, riid) {
+		return Zero, fmt.Errorf("%s: RP can not be embedded with prefix length %d: %w", rp, plen, ErrInvalid)
+	}
+	flags := FlagRendezvous | FlagPrefix | FlagTransient
+	return multicast6(flags, scope, riid<<40|uint64(plen)<<32, rp.set.high, group), nil
+}
+
+func (i IP) EmbeddedRP() (IP, error) {
+	if !i.MulticastFlags().Rendezvous() {
+		return Zero, fmt.Errorf("%s: no embedded RP: %w", i, ErrMulticast)
+	}
+	var (
+		riid = (i.set.high >> 40) & 0xf
+		plen = uint8(i.set.high >> 32)
+	)
+	if plen == 0 || plen > netmask64 {
+		return Zero, fmt.Errorf("%s: invalid embedded prefix length %d: %w", i, plen, ErrInvalid)
+	}
+	prefix := i.set.high<<32 | i.set.low>>32
+	return rpAddress(prefix, plen, riid), nil
+}
+
+func SolicitedNode(ip IP) (IP, error) {
+	if !ip.Is6() {
+		return Zero, fmt.Errorf("%s: IPv6 address expected: %w", ip, ErrInvalid)
+	}
+	var set bitset
+	set.high = 0xff02 << 48
+	set.low = 0x1_ff00_0000 | ip.set.low&0xff_ffff
+	return makeIP(set, z6), nil
+}
+
+func (i IP) MulticastMAC() (net.HardwareAddr, error) {
+	if !i.IsMulticast() {
+		return nil, fmt.Errorf("%s: %w", i, ErrMulticast)
+	}
+	mac := make(net.HardwareAddr, 6)
+	if i.zone == z4 {
+		mac[0], mac[1], mac[2] = 0x01, 0x00, 0x5e
+		mac[3] = byte(i.set.low>>16) & 0x7f
+	} else {
+		mac[0], mac[1] = 0x33, 0x33
+		mac[2] = byte(i.set.low >> 24)
+		mac[3] = byte(i.set.low >> 16)
+	}
+	mac[4] = byte(i.set.low >> 8)
+	mac[5] = byte(i.set.low)
+	return mac, nil
+}
+
+func multicast6(flags MulticastFlags, scope Scope, extra, prefix uint64, group uint32) IP {
+	var set bitset
+	set.high = 0xff<<56 | uint64(flags)<<52 | uint64(scope&0xf)<<48 | extra | prefix>>32
+	set.low = prefix<<32 | uint64(group)
+	return makeIP(set, z6)
+}
+
+func rpAddress(prefix uint64, plen uint8, riid uint64) IP {
+	var set bitset
+	if plen < netmask64 {
+		prefix &^= (1 << (netmask64 - plen)) - 1
+	}
+	set.high = prefix
+	set.low = riid
+	return makeIP(set, z6)
+}
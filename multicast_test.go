@@ -0,0 +1,136 @@
+package ipaddr
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMulticastScope(t *testing.T) {
+	data := []struct {
+		Addr  string
+		Scope Scope
+		Flags MulticastFlags
+	}{
+		{
+			Addr:  "ff02::1",
+			Scope: ScopeLink,
+		},
+		{
+			Addr:  "ff15::db8",
+			Scope: ScopeSite,
+			Flags: FlagTransient,
+		},
+		{
+			Addr:  "ff7e:140:2001:db8:beef::1",
+			Scope: ScopeGlobal,
+			Flags: FlagRendezvous | FlagPrefix | FlagTransient,
+		},
+		{
+			Addr:  "224.0.0.251",
+			Scope: ScopeLink,
+		},
+		{
+			Addr:  "239.192.1.1",
+			Scope: ScopeOrganization,
+		},
+		{
+			Addr:  "233.252.0.1",
+			Scope: ScopeGlobal,
+		},
+	}
+	for _, d := range data {
+		ip, err := ParseIP(d.Addr)
+		if err != nil {
+			t.Errorf("%s: fail to parse %s", d.Addr, err)
+			continue
+		}
+		if got := ip.MulticastScope(); got != d.Scope {
+			t.Errorf("%s: scope mismatched! want %s, got %s", d.Addr, d.Scope, got)
+		}
+		if got := ip.MulticastFlags(); got != d.Flags {
+			t.Errorf("%s: flags mismatched! want %s, got %s", d.Addr, d.Flags, got)
+		}
+	}
+}
+
+func TestMulticastPrefix(t *testing.T) {
+	nw, _ := ParseNet("3ffe:ffff:1::/48")
+	ip, err := MulticastPrefix(nw, ScopeGlobal, 0x1234)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "ff3e:30:3ffe:ffff:1::1234"; ip.String() != want {
+		t.Errorf("results mismatched! want %s, got %s", want, ip)
+	}
+}
+
+func TestMulticastRP(t *testing.T) {
+	rp, _ := ParseIP("2001:db8:beef:feed::9")
+	ip, err := MulticastRP(rp, 64, ScopeGlobal, 0x12345678)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "ff7e:940:2001:db8:beef:feed:1234:5678"; ip.String() != want {
+		t.Errorf("results mismatched! want %s, got %s", want, ip)
+	}
+	got, err := ip.EmbeddedRP()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != rp {
+		t.Errorf("rp mismatched! want %s, got %s", rp, got)
+	}
+	if _, err := MulticastRP(rp, 32, ScopeGlobal, 1); err == nil {
+		t.Errorf("rp with non zero bits after prefix should fail")
+	}
+}
+
+func TestSolicitedNode(t *testing.T) {
+	ip, _ := ParseIP("2001:db8::2aa:ff:fe28:9c5a")
+	got, err := SolicitedNode(ip)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "ff02::1:ff28:9c5a"; got.String() != want {
+		t.Errorf("results mismatched! want %s, got %s", want, got)
+	}
+	ip, _ = ParseIP("192.0.2.1")
+	if _, err := SolicitedNode(ip); !errors.Is(err, ErrInvalid) {
+		t.Errorf("%s: expected ErrInvalid, got %v", ip, err)
+	}
+}
+
+func TestMulticastMAC(t *testing.T) {
+	data := []struct {
+		Addr string
+		Want string
+	}{
+		{
+			Addr: "239.255.255.250",
+			Want: "01:00:5e:7f:ff:fa",
+		},
+		{
+			Addr: "224.128.0.1",
+			Want: "01:00:5e:00:00:01",
+		},
+		{
+			Addr: "ff02::1:ff28:9c5a",
+			Want: "33:33:ff:28:9c:5a",
+		},
+	}
+	for _, d := range data {
+		ip, err := ParseIP(d.Addr)
+		if err != nil {
+			t.Errorf("%s: fail to parse %s", d.Addr, err)
+			continue
+		}
+		mac, err := ip.MulticastMAC()
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", d.Addr, err)
+			continue
+		}
+		if got := mac.String(); got != d.Want {
+			t.Errorf("%s: results mismatched! want %s, got %s", d.Addr, d.Want, got)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+//go:build go1.18
+// +build go1.18
+
+package ipaddr
+
+import (
+	"net/netip"
+)
+
+func FromAddr(addr netip.Addr) (IP, error) {
+	if !addr.IsValid() {
+		return Zero, ErrInvalid
+	}
+	if addr.Is4() {
+		b := addr.As4()
+		return IPv4(b[0], b[1], b[2], b[3]), nil
+	}
+	b := addr.As16()
+	ip := makeIP(fromBytes(b[:]), z6)
+	return ip.WithZone(addr.Zone()), nil
+}
+
+func FromPrefix(prefix netip.Prefix) (Net, error) {
+	a, err := IfAddrFromPrefix(prefix)
+	if err != nil {
+		return Net{}, err
+	}
+	return a.Net(), nil
+}
+
+func IfAddrFromPrefix(prefix netip.Prefix) (IfAddr, error) {
+	if !prefix.IsValid() {
+		return IfAddr{}, ErrInvalid
+	}
+	ip, err := FromAddr(prefix.Addr())
+	if err != nil {
+		return IfAddr{}, err
+	}
+	return ip.IfAddr(uint8(prefix.Bits()))
+}
+
+func FromAddrPort(ap netip.AddrPort) (IP, uint16, error) {
+	ip, err := FromAddr(ap.Addr())
+	return ip, ap.Port(), err
+}
+
+func (i IP) ToAddr() netip.Addr {
+	var b [16]byte
+	switch i.zone {
+	case z4:
+		v := i.set.low
+		return netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+	case z6:
+		i.set.copy(b[:])
+		return netip.AddrFrom16(b).WithZone(i.zid)
+	default:
+		return netip.Addr{}
+	}
+}
+
+func (i IP) ToAddrPort(port uint16) netip.AddrPort {
+	return netip.AddrPortFrom(i.ToAddr(), port)
+}
+
+func (a IfAddr) ToPrefix() netip.Prefix {
+	return netip.PrefixFrom(a.ip.ToAddr().WithZone(""), a.Len())
+}
+
+func (n Net) ToPrefix() netip.Prefix {
+	return netip.PrefixFrom(n.ip.ToAddr(), n.mask.ones())
+}
@@ -0,0 +1,58 @@
+//go:build go1.18
+// +build go1.18
+
+package ipaddr
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestNetipAddr(t *testing.T) {
+	for _, str := range []string{"192.168.1.1", "2001:db8::1", "fe80::1%eth0", "::ffff:c0a8:101", "::"} {
+		addr := netip.MustParseAddr(str)
+		ip, err := FromAddr(addr)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", str, err)
+			continue
+		}
+		if ip.Is4() != addr.Is4() || ip.Zone() != addr.Zone() {
+			t.Errorf("%s: results mismatched! want %s, got %s", str, addr, ip)
+		}
+		if back := ip.ToAddr(); back != addr {
+			t.Errorf("%s: roundtrip failed! got %s", str, back)
+		}
+	}
+	if _, err := FromAddr(netip.Addr{}); err == nil {
+		t.Errorf("invalid address converted successfully")
+	}
+}
+
+func TestNetipPrefix(t *testing.T) {
+	for _, str := range []string{"10.1.2.3/24", "2001:db8::1/64", "0.0.0.0/0"} {
+		prefix := netip.MustParsePrefix(str)
+		a, err := IfAddrFromPrefix(prefix)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", str, err)
+			continue
+		}
+		if back := a.ToPrefix(); back != prefix {
+			t.Errorf("%s: roundtrip failed! got %s", str, back)
+		}
+		nw, _ := FromPrefix(prefix)
+		if back := nw.ToPrefix(); back != prefix.Masked() {
+			t.Errorf("%s: network mismatched! want %s, got %s", str, prefix.Masked(), back)
+		}
+	}
+}
+
+func TestNetipAddrPort(t *testing.T) {
+	ap := netip.MustParseAddrPort("[fe80::1%eth0]:8080")
+	ip, port, err := FromAddrPort(ap)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if back := ip.ToAddrPort(port); back != ap {
+		t.Errorf("roundtrip failed! want %s, got %s", ap, back)
+	}
+}
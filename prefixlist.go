@@ -0,0 +1,273 @@
+package ipaddr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type PrefixRange struct {
+	Net Net
+	Min int
+	Max int
+}
+
+func ExactRange(nw Net) PrefixRange {
+	return PrefixRange{
+		Net: nw,
+		Min: nw.Size(),
+		Max: nw.Size(),
+	}
+}
+
+func ParsePrefixRange(str string) (PrefixRange, error) {
+	fields := strings.Fields(str)
+	if len(fields) == 0 {
+		return PrefixRange{}, fmt.Errorf("empty prefix range: %w", ErrInvalid)
+	}
+	nw, err := ParseNet(fields[0])
+	if err != nil {
+		return PrefixRange{}, err
+	}
+	var (
+		r    = ExactRange(nw)
+		bits = nw.ip.bitLen()
+	)
+	switch args := fields[1:]; {
+	case len(args) == 0 || (len(args) == 1 && args[0] == "exact"):
+	case len(args) == 1 && args[0] == "orlonger":
+		r.Max = bits
+	case len(args) == 1 && args[0] == "longer":
+		r.Min, r.Max = r.Min+1, bits
+	case len(args) == 2 && args[0] == "upto":
+		r.Max, err = parseRangeLen(args[1], true)
+	case len(args) == 2 && args[0] == "prefix-length-range":
+		x := strings.Index(args[1], "-")
+		if x < 0 {
+			return r, fmt.Errorf("%s: invalid prefix-length-range: %w", str, ErrInvalid)
+		}
+		if r.Min, err = parseRangeLen(args[1][:x], true); err == nil {
+			r.Max, err = parseRangeLen(args[1][x+1:], true)
+		}
+	default:
+		r.Max = -1
+		for i := 0; i < len(args) && err == nil; i += 2 {
+			if i+1 >= len(args) {
+				return r, fmt.Errorf("%s: missing value after %s: %w", str, args[i], ErrInvalid)
+			}
+			switch args[i] {
+			case "ge":
+				r.Min, err = parseRangeLen(args[i+1], false)
+				if r.Max < 0 {
+					r.Max = bits
+				}
+			case "le":
+				r.Max, err = parseRangeLen(args[i+1], false)
+			default:
+				return r, fmt.Errorf("%s: unknown keyword %s: %w", str, args[i], ErrInvalid)
+			}
+		}
+	}
+	if err != nil {
+		return r, fmt.Errorf("%s: %w", str, err)
+	}
+	if r.Min < nw.Size() || r.Min > r.Max || r.Max > bits {
+		return r, fmt.Errorf("%s: invalid length range %d-%d: %w", str, r.Min, r.Max, ErrInvalid)
+	}
+	return r, nil
+}
+
+func (r PrefixRange) Match(nw Net) bool {
+	if !r.Net.covers(nw) {
+		return false
+	}
+	size := nw.Size()
+	return size >= r.Min && size <= r.Max
+}
+
+func (r PrefixRange) String() string {
+	var (
+		str  = r.Net.String()
+		size = r.Net.Size()
+		bits = r.Net.ip.bitLen()
+	)
+	switch {
+	case r.Min == size && r.Max == size:
+	case r.Min == size:
+		str += " le " + strconv.Itoa(r.Max)
+	case r.Max == bits:
+		str += " ge " + strconv.Itoa(r.Min)
+	default:
+		str += " ge " + strconv.Itoa(r.Min) + " le " + strconv.Itoa(r.Max)
+	}
+	return str
+}
+
+func parseRangeLen(str string, slash bool) (int, error) {
+	if slash {
+		if !strings.HasPrefix(str, "/") {
+			return 0, fmt.Errorf("%s: prefix length must start with /: %w", str, ErrInvalid)
+		}
+		str = str[1:]
+	}
+	n, err := strconv.ParseUint(str, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid prefix length: %w", str, ErrInvalid)
+	}
+	return int(n), nil
+}
+
+type PrefixAction int8
+
+const (
+	PrefixDeny PrefixAction = iota
+	PrefixPermit
+)
+
+func (a PrefixAction) String() string {
+	switch a {
+	case PrefixPermit:
+		return "permit"
+	case PrefixDeny:
+		return "deny"
+	default:
+		return "<unknown>"
+	}
+}
+
+type PrefixEntry struct {
+	Seq    int
+	Action PrefixAction
+	Range  PrefixRange
+}
+
+func (e PrefixEntry) String() string {
+	return fmt.Sprintf("seq %d %s %s", e.Seq, e.Action, e.Range)
+}
+
+const seqStep = 5
+
+type PrefixList struct {
+	Name    string
+	Entries []PrefixEntry
+}
+
+func ParsePrefixList(r io.Reader) (*PrefixList, error) {
+	var (
+		list PrefixList
+		scan = bufio.NewScanner(r)
+	)
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || line[0] == '!' || line[0] == '#' || isPrefixDescription(line) {
+			continue
+		}
+		name, e, err := parsePrefixEntry(line)
+		if err != nil {
+			return nil, err
+		}
+		if list.Name == "" {
+			list.Name = name
+		} else if name != "" && name != list.Name {
+			return nil, fmt.Errorf("%s: unexpected prefix-list %s (want %s)", line, name, list.Name)
+		}
+		list.Add(e)
+	}
+	return &list, scan.Err()
+}
+
+func (p *PrefixList) Add(e PrefixEntry) {
+	if e.Seq <= 0 {
+		e.Seq = seqStep
+		if n := len(p.Entries); n > 0 {
+			e.Seq += p.Entries[n-1].Seq
+		}
+	}
+	x := sort.Search(len(p.Entries), func(i int) bool {
+		return p.Entries[i].Seq >= e.Seq
+	})
+	if x < len(p.Entries) && p.Entries[x].Seq == e.Seq {
+		p.Entries[x] = e
+		return
+	}
+	p.Entries = append(p.Entries, e)
+	copy(p.Entries[x+1:], p.Entries[x:])
+	p.Entries[x] = e
+}
+
+func (p *PrefixList) Evaluate(nw Net) (PrefixEntry, bool) {
+	for _, e := range p.Entries {
+		if e.Range.Match(nw) {
+			return e, true
+		}
+	}
+	return PrefixEntry{}, false
+}
+
+func (p *PrefixList) Permit(nw Net) bool {
+	e, ok := p.Evaluate(nw)
+	return ok && e.Action == PrefixPermit
+}
+
+func (p *PrefixList) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, e := range p.Entries {
+		line := e.String()
+		if p.Name != "" {
+			family := "ip"
+			if e.Range.Net.ip.zone == z6 {
+				family = "ipv6"
+			}
+			line = family + " prefix-list " + p.Name + " " + line
+		}
+		n, err := io.WriteString(w, line+"\n")
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func isPrefixDescription(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) >= 4 && (fields[0] == "ip" || fields[0] == "ipv6") && fields[1] == "prefix-list" && fields[3] == "description"
+}
+
+func parsePrefixEntry(line string) (string, PrefixEntry, error) {
+	var (
+		e      PrefixEntry
+		name   string
+		fields = strings.Fields(line)
+	)
+	if len(fields) >= 3 && (fields[0] == "ip" || fields[0] == "ipv6") && fields[1] == "prefix-list" {
+		name, fields = fields[2], fields[3:]
+	}
+	if len(fields) >= 2 && fields[0] == "seq" {
+		seq, err := strconv.Atoi(fields[1])
+		if err != nil || seq <= 0 {
+			return name, e, fmt.Errorf("%s: invalid sequence number %s", line, fields[1])
+		}
+		e.Seq, fields = seq, fields[2:]
+	}
+	if len(fields) < 2 {
+		return name, e, fmt.Errorf("%s: missing action or prefix", line)
+	}
+	switch fields[0] {
+	case "permit":
+		e.Action = PrefixPermit
+	case "deny":
+		e.Action = PrefixDeny
+	default:
+		return name, e, fmt.Errorf("%s: unknown action %s", line, fields[0])
+	}
+	r, err := ParsePrefixRange(strings.Join(fields[1:], " "))
+	if err != nil {
+		return name, e, err
+	}
+	e.Range = r
+	return name, e, nil
+}
@@ -0,0 +1,136 @@
+package ipaddr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePrefixRange(t *testing.T) {
+	data := []struct {
+		Input string
+		Min   int
+		Max   int
+		Want  string
+		Err   bool
+	}{
+		{Input: "10.0.0.0/8", Min: 8, Max: 8, Want: "10.0.0.0/8"},
+		{Input: "10.0.0.0/8 ge 16", Min: 16, Max: 32, Want: "10.0.0.0/8 ge 16"},
+		{Input: "10.0.0.0/8 le 24", Min: 8, Max: 24, Want: "10.0.0.0/8 le 24"},
+		{Input: "10.0.0.0/8 ge 16 le 24", Min: 16, Max: 24, Want: "10.0.0.0/8 ge 16 le 24"},
+		{Input: "10.0.0.0/8 exact", Min: 8, Max: 8},
+		{Input: "10.0.0.0/8 orlonger", Min: 8, Max: 32},
+		{Input: "10.0.0.0/8 longer", Min: 9, Max: 32},
+		{Input: "10.0.0.0/8 upto /24", Min: 8, Max: 24},
+		{Input: "2001:db8::/32 prefix-length-range /48-/64", Min: 48, Max: 64},
+		{Input: "10.0.0.0/8 ge 24 le 16", Err: true},
+		{Input: "10.0.0.0/16 ge 8", Err: true},
+		{Input: "10.0.0.0/8 le 33", Err: true},
+		{Input: "10.0.0.0/8 upto 24", Err: true},
+		{Input: "10.0.0.0/8 ge", Err: true},
+	}
+	for _, d := range data {
+		r, err := ParsePrefixRange(d.Input)
+		if d.Err {
+			if err == nil {
+				t.Errorf("%s: invalid range parsed successfully", d.Input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", d.Input, err)
+			continue
+		}
+		if r.Min != d.Min || r.Max != d.Max {
+			t.Errorf("%s: lengths mismatched! want %d-%d, got %d-%d", d.Input, d.Min, d.Max, r.Min, r.Max)
+		}
+		if d.Want != "" && r.String() != d.Want {
+			t.Errorf("%s: results mismatched! want %s, got %s", d.Input, d.Want, r)
+		}
+	}
+}
+
+func TestPrefixRangeMatch(t *testing.T) {
+	r, _ := ParsePrefixRange("10.0.0.0/8 ge 16 le 24")
+	data := []struct {
+		Net  string
+		Want bool
+	}{
+		{Net: "10.1.0.0/16", Want: true},
+		{Net: "10.1.2.0/24", Want: true},
+		{Net: "10.0.0.0/8", Want: false},
+		{Net: "10.1.2.0/25", Want: false},
+		{Net: "11.1.0.0/16", Want: false},
+		{Net: "::/16", Want: false},
+	}
+	for _, d := range data {
+		nw, _ := ParseNet(d.Net)
+		if got := r.Match(nw); got != d.Want {
+			t.Errorf("%s: results mismatched! want %t, got %t", d.Net, d.Want, got)
+		}
+	}
+}
+
+func TestPrefixList(t *testing.T) {
+	const text = `
+! customer filter
+ip prefix-list CUSTOMER description routes learned from customers
+ip prefix-list CUSTOMER seq 10 deny 10.1.0.0/16 le 32
+ip prefix-list CUSTOMER seq 20 permit 10.0.0.0/8 ge 16 le 24
+ip prefix-list CUSTOMER seq 5 deny 10.1.1.0/24
+ip prefix-list CUSTOMER permit 192.0.2.0/24
+`
+	list, err := ParsePrefixList(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if list.Name != "CUSTOMER" || len(list.Entries) != 4 {
+		t.Fatalf("prefix-list mismatched! got %s with %d entries", list.Name, len(list.Entries))
+	}
+	if last := list.Entries[3]; last.Seq != 25 {
+		t.Errorf("sequence mismatched! want 25, got %d", last.Seq)
+	}
+	data := []struct {
+		Net  string
+		Want bool
+	}{
+		{Net: "10.1.1.0/24", Want: false},
+		{Net: "10.1.2.0/24", Want: false},
+		{Net: "10.2.0.0/16", Want: true},
+		{Net: "10.2.0.0/25", Want: false},
+		{Net: "192.0.2.0/24", Want: true},
+		{Net: "198.51.100.0/24", Want: false},
+	}
+	for _, d := range data {
+		nw, _ := ParseNet(d.Net)
+		if got := list.Permit(nw); got != d.Want {
+			t.Errorf("%s: results mismatched! want %t, got %t", d.Net, d.Want, got)
+		}
+	}
+}
+
+func TestPrefixListWriteTo(t *testing.T) {
+	var list PrefixList
+	list.Name = "EXPORT"
+	for _, str := range []string{"192.0.2.0/24", "2001:db8::/32 le 48"} {
+		r, err := ParsePrefixRange(str)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", str, err)
+		}
+		list.Add(PrefixEntry{Action: PrefixPermit, Range: r})
+	}
+	var str strings.Builder
+	if _, err := list.WriteTo(&str); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := "ip prefix-list EXPORT seq 5 permit 192.0.2.0/24\nipv6 prefix-list EXPORT seq 10 permit 2001:db8::/32 le 48\n"
+	if got := str.String(); got != want {
+		t.Fatalf("output mismatched! want %q, got %q", want, got)
+	}
+	other, err := ParsePrefixList(strings.NewReader(want))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if other.Name != list.Name || len(other.Entries) != len(list.Entries) {
+		t.Fatalf("round trip mismatched! got %s with %d entries", other.Name, len(other.Entries))
+	}
+}
@@ -0,0 +1,378 @@
+// Package proxyproto reads and writes the headers of the PROXY protocol
+// (versions 1 and 2) used by load balancers to forward the addresses of the
+// original connection.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/midbel/ipaddr"
+)
+
+var (
+	ErrNoHeader = errors.New("proxyproto: no header")
+	ErrInvalid  = errors.New("proxyproto: invalid header")
+	ErrChecksum = errors.New("proxyproto: checksum mismatch")
+)
+
+const (
+	signatureV1 = "PROXY "
+	signatureV2 = "\r\n\r\n\x00\r\nQUIT\n"
+	maxLineV1   = 107
+	headerLenV2 = 16
+)
+
+const (
+	familyUnspec = 0x0
+	familyInet   = 0x1
+	familyInet6  = 0x2
+	familyUnix   = 0x3
+)
+
+const (
+	addrLenInet  = 12
+	addrLenInet6 = 36
+	addrLenUnix  = 216
+)
+
+type Version uint8
+
+const (
+	V1 Version = iota + 1
+	V2
+)
+
+type Command uint8
+
+const (
+	Local Command = iota
+	Proxy
+)
+
+func (c Command) String() string {
+	switch c {
+	case Local:
+		return "LOCAL"
+	case Proxy:
+		return "PROXY"
+	default:
+		return "<unknown>"
+	}
+}
+
+type Protocol uint8
+
+const (
+	Unspec Protocol = iota
+	Stream
+	Datagram
+)
+
+func (p Protocol) String() string {
+	switch p {
+	case Unspec:
+		return "UNSPEC"
+	case Stream:
+		return "STREAM"
+	case Datagram:
+		return "DGRAM"
+	default:
+		return "<unknown>"
+	}
+}
+
+// Header describes the original connection. With the Local command (health
+// checks of the proxy or UNKNOWN in version 1) and with addresses of the UNIX
+// family, Source and Destination are left empty and the addresses of the
+// connection should be used instead.
+type Header struct {
+	Version         Version
+	Command         Command
+	Protocol        Protocol
+	Source          ipaddr.IP
+	Destination     ipaddr.IP
+	SourcePort      uint16
+	DestinationPort uint16
+	TLVs            []TLV
+}
+
+func Read(r *bufio.Reader) (*Header, error) {
+	sig, err := r.Peek(len(signatureV1))
+	if err != nil {
+		if err == io.EOF {
+			err = ErrNoHeader
+		}
+		return nil, err
+	}
+	if string(sig) == signatureV1 {
+		return readV1(r)
+	}
+	if sig, err = r.Peek(len(signatureV2)); err == nil && string(sig) == signatureV2 {
+		return readV2(r)
+	}
+	return nil, ErrNoHeader
+}
+
+func readV1(r *bufio.Reader) (*Header, error) {
+	var line []byte
+	for len(line) <= maxLineV1 {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalid, err)
+		}
+		line = append(line, c)
+		if c == '\n' {
+			break
+		}
+	}
+	if len(line) > maxLineV1 || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("%w: line too long or not terminated by CRLF", ErrInvalid)
+	}
+	fields := strings.Split(string(line[len(signatureV1):len(line)-2]), " ")
+	h := Header{Version: V1}
+	switch fields[0] {
+	case "UNKNOWN":
+		h.Command = Local
+		return &h, nil
+	case "TCP4", "TCP6":
+		h.Command, h.Protocol = Proxy, Stream
+	default:
+		return nil, fmt.Errorf("%w: unknown protocol %q", ErrInvalid, fields[0])
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 fields, got %d", ErrInvalid, len(fields))
+	}
+	var err error
+	if h.Source, err = parseAddr(fields[1], fields[0] == "TCP6"); err != nil {
+		return nil, err
+	}
+	if h.Destination, err = parseAddr(fields[2], fields[0] == "TCP6"); err != nil {
+		return nil, err
+	}
+	if h.SourcePort, err = parsePort(fields[3]); err != nil {
+		return nil, err
+	}
+	if h.DestinationPort, err = parsePort(fields[4]); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+func parseAddr(str string, v6 bool) (ipaddr.IP, error) {
+	ip, err := ipaddr.ParseIP(str)
+	if err != nil {
+		return ipaddr.Zero, fmt.Errorf("%w: %s", ErrInvalid, err)
+	}
+	if ip.Is6() != v6 || ip.Zone() != "" {
+		return ipaddr.Zero, fmt.Errorf("%w: %s: address family mismatch", ErrInvalid, str)
+	}
+	return ip, nil
+}
+
+func parsePort(str string) (uint16, error) {
+	if len(str) > 1 && str[0] == '0' {
+		return 0, fmt.Errorf("%w: %s: leading zero in port", ErrInvalid, str)
+	}
+	n, err := strconv.ParseUint(str, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s: invalid port", ErrInvalid, str)
+	}
+	return uint16(n), nil
+}
+
+func readV2(r *bufio.Reader) (*Header, error) {
+	buf := make([]byte, headerLenV2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalid, err)
+	}
+	if buf[12]>>4 != 2 {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalid, buf[12]>>4)
+	}
+	size := int(binary.BigEndian.Uint16(buf[14:]))
+	buf = append(buf, make([]byte, size)...)
+	if _, err := io.ReadFull(r, buf[headerLenV2:]); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalid, err)
+	}
+	h := Header{Version: V2}
+	switch cmd := buf[12] & 0xf; cmd {
+	case 0:
+		h.Command = Local
+	case 1:
+		h.Command = Proxy
+	default:
+		return nil, fmt.Errorf("%w: unknown command %d", ErrInvalid, cmd)
+	}
+	var (
+		family = buf[13] >> 4
+		body   = buf[headerLenV2:]
+		alen   int
+	)
+	switch h.Protocol = Protocol(buf[13] & 0xf); {
+	case h.Protocol > Datagram:
+		return nil, fmt.Errorf("%w: unknown protocol %d", ErrInvalid, h.Protocol)
+	case family == familyUnspec:
+	case family == familyInet:
+		alen = addrLenInet
+	case family == familyInet6:
+		alen = addrLenInet6
+	case family == familyUnix:
+		alen = addrLenUnix
+	default:
+		return nil, fmt.Errorf("%w: unknown address family %d", ErrInvalid, family)
+	}
+	if len(body) < alen {
+		return nil, fmt.Errorf("%w: addresses truncated", ErrInvalid)
+	}
+	if h.Command == Proxy && (family == familyInet || family == familyInet6) {
+		n := (alen - 4) / 2
+		h.Source = makeIP(body[:n])
+		h.Destination = makeIP(body[n : 2*n])
+		h.SourcePort = binary.BigEndian.Uint16(body[2*n:])
+		h.DestinationPort = binary.BigEndian.Uint16(body[2*n+2:])
+	}
+	tlvs, err := parseTLVs(body[alen:])
+	if err != nil {
+		return nil, err
+	}
+	h.TLVs = tlvs
+	if v, ok := h.Get(TypeCRC32C); ok {
+		if err := checkCRC(buf, v); err != nil {
+			return nil, err
+		}
+	}
+	return &h, nil
+}
+
+func checkCRC(buf, sum []byte) error {
+	if len(sum) != 4 {
+		return fmt.Errorf("%w: invalid crc32c length %d", ErrInvalid, len(sum))
+	}
+	want := binary.BigEndian.Uint32(sum)
+	copy(sum, []byte{0, 0, 0, 0})
+	got := crc32.Checksum(buf, castagnoli)
+	binary.BigEndian.PutUint32(sum, want)
+	if got != want {
+		return ErrChecksum
+	}
+	return nil
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+func makeIP(b []byte) ipaddr.IP {
+	if len(b) == 4 {
+		return ipaddr.IPv4(b[0], b[1], b[2], b[3])
+	}
+	var g [8]uint16
+	for i := range g {
+		g[i] = binary.BigEndian.Uint16(b[i*2:])
+	}
+	return ipaddr.IPv6(g[0], g[1], g[2], g[3], g[4], g[5], g[6], g[7])
+}
+
+func (h *Header) WriteTo(w io.Writer) (int64, error) {
+	var (
+		buf []byte
+		err error
+	)
+	switch h.Version {
+	case V1:
+		buf, err = h.appendV1(nil)
+	case V2:
+		buf, err = h.appendV2(nil)
+	default:
+		err = fmt.Errorf("%w: unsupported version %d", ErrInvalid, h.Version)
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+func (h *Header) appendV1(dst []byte) ([]byte, error) {
+	dst = append(dst, signatureV1...)
+	if h.Command == Local || h.Protocol == Unspec {
+		return append(dst, "UNKNOWN\r\n"...), nil
+	}
+	if h.Protocol != Stream {
+		return nil, fmt.Errorf("%w: %s not supported in version 1", ErrInvalid, h.Protocol)
+	}
+	if err := h.checkAddrs(); err != nil {
+		return nil, err
+	}
+	if h.Source.Is4() {
+		dst = append(dst, "TCP4 "...)
+	} else {
+		dst = append(dst, "TCP6 "...)
+	}
+	dst = h.Source.AppendTo(dst)
+	dst = append(dst, ' ')
+	dst = h.Destination.AppendTo(dst)
+	dst = append(dst, ' ')
+	dst = strconv.AppendUint(dst, uint64(h.SourcePort), 10)
+	dst = append(dst, ' ')
+	dst = strconv.AppendUint(dst, uint64(h.DestinationPort), 10)
+	return append(dst, "\r\n"...), nil
+}
+
+func (h *Header) appendV2(dst []byte) ([]byte, error) {
+	start := len(dst)
+	dst = append(dst, signatureV2...)
+	dst = append(dst, 0x20|byte(h.Command), 0, 0, 0)
+	if h.Command == Proxy {
+		if err := h.checkAddrs(); err != nil {
+			return nil, err
+		}
+		family := byte(familyInet)
+		if h.Source.Is6() {
+			family = familyInet6
+		}
+		dst[start+13] = family<<4 | byte(h.Protocol)
+		dst = append(dst, h.Source.ToStdIP()...)
+		dst = append(dst, h.Destination.ToStdIP()...)
+		dst = append(dst, byte(h.SourcePort>>8), byte(h.SourcePort))
+		dst = append(dst, byte(h.DestinationPort>>8), byte(h.DestinationPort))
+	}
+	crc := -1
+	for _, t := range h.TLVs {
+		if len(t.Value) > 0xffff {
+			return nil, fmt.Errorf("%w: tlv %#x too long", ErrInvalid, t.Type)
+		}
+		if t.Type == TypeCRC32C {
+			dst = append(dst, t.Type, 0, 4)
+			crc = len(dst)
+			dst = append(dst, 0, 0, 0, 0)
+			continue
+		}
+		dst = append(dst, t.Type, byte(len(t.Value)>>8), byte(len(t.Value)))
+		dst = append(dst, t.Value...)
+	}
+	size := len(dst) - start - headerLenV2
+	if size > 0xffff {
+		return nil, fmt.Errorf("%w: header too long", ErrInvalid)
+	}
+	binary.BigEndian.PutUint16(dst[start+14:], uint16(size))
+	if crc >= 0 {
+		binary.BigEndian.PutUint32(dst[crc:], crc32.Checksum(dst[start:], castagnoli))
+	}
+	return dst, nil
+}
+
+func (h *Header) checkAddrs() error {
+	switch {
+	case h.Source.Is4() && h.Destination.Is4():
+	case h.Source.Is6() && h.Destination.Is6():
+	default:
+		return fmt.Errorf("%w: source and destination must be of the same family", ErrInvalid)
+	}
+	return nil
+}
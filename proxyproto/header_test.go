@@ -0,0 +1,205 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/midbel/ipaddr"
+)
+
+func TestReadV1(t *testing.T) {
+	data := []struct {
+		Input string
+		Src   string
+		Dst   string
+		Ports [2]uint16
+		Cmd   Command
+	}{
+		{
+			Input: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nGET /",
+			Src:   "192.0.2.1",
+			Dst:   "198.51.100.1",
+			Ports: [2]uint16{56324, 443},
+			Cmd:   Proxy,
+		},
+		{
+			Input: "PROXY TCP6 2001:db8::1 2001:db8::2 4711 80\r\n",
+			Src:   "2001:db8::1",
+			Dst:   "2001:db8::2",
+			Ports: [2]uint16{4711, 80},
+			Cmd:   Proxy,
+		},
+		{
+			Input: "PROXY UNKNOWN ffff:f...f:ffff ffff:f...f:ffff 65535 65535\r\n",
+			Cmd:   Local,
+		},
+	}
+	for _, d := range data {
+		r := bufio.NewReader(strings.NewReader(d.Input))
+		h, err := Read(r)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", d.Input, err)
+			continue
+		}
+		if h.Version != V1 || h.Command != d.Cmd {
+			t.Errorf("%q: header mismatched! got %+v", d.Input, h)
+		}
+		if d.Cmd == Local {
+			continue
+		}
+		if h.Source.String() != d.Src || h.Destination.String() != d.Dst {
+			t.Errorf("%q: addresses mismatched! got %s -> %s", d.Input, h.Source, h.Destination)
+		}
+		if h.SourcePort != d.Ports[0] || h.DestinationPort != d.Ports[1] {
+			t.Errorf("%q: ports mismatched! got %d -> %d", d.Input, h.SourcePort, h.DestinationPort)
+		}
+	}
+	r := bufio.NewReader(strings.NewReader(data[0].Input))
+	Read(r)
+	if rest, _ := r.ReadString(0); rest != "GET /" {
+		t.Errorf("payload mismatched! got %q", rest)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	data := []struct {
+		Input string
+		Err   error
+	}{
+		{Input: "GET / HTTP/1.1\r\n", Err: ErrNoHeader},
+		{Input: "", Err: ErrNoHeader},
+		{Input: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\n", Err: ErrInvalid},
+		{Input: "PROXY TCP4 192.0.2.1 198.51.100.1 56324\r\n", Err: ErrInvalid},
+		{Input: "PROXY TCP4 2001:db8::1 198.51.100.1 56324 443\r\n", Err: ErrInvalid},
+		{Input: "PROXY TCP6 192.0.2.1 198.51.100.1 56324 443\r\n", Err: ErrInvalid},
+		{Input: "PROXY TCP4 192.0.2.1 198.51.100.1 056324 443\r\n", Err: ErrInvalid},
+		{Input: "PROXY TCP4 192.0.2.1 198.51.100.1 65536 443\r\n", Err: ErrInvalid},
+		{Input: "PROXY UDP4 192.0.2.1 198.51.100.1 53 53\r\n", Err: ErrInvalid},
+		{Input: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443" + strings.Repeat(" ", 80) + "\r\n", Err: ErrInvalid},
+		{Input: signatureV2 + "\x21\x11\x00\x0c\xc0\x00\x02", Err: ErrInvalid},
+		{Input: signatureV2 + "\x31\x11\x00\x00", Err: ErrInvalid},
+		{Input: signatureV2 + "\x23\x11\x00\x00", Err: ErrInvalid},
+		{Input: signatureV2 + "\x21\x41\x00\x00", Err: ErrInvalid},
+		{Input: signatureV2 + "\x21\x11\x00\x0e\xc0\x00\x02\x01\xc6\x33\x64\x01\x00\x50\x01\xbb\x01\x00", Err: ErrInvalid},
+	}
+	for _, d := range data {
+		_, err := Read(bufio.NewReader(strings.NewReader(d.Input)))
+		if !errors.Is(err, d.Err) {
+			t.Errorf("%q: expected %v, got %v", d.Input, d.Err, err)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	src, _ := ipaddr.ParseIP("2001:db8::1")
+	dst, _ := ipaddr.ParseIP("2001:db8::2")
+	ssl := SSL{
+		Client:  ClientSSL | ClientCertConn,
+		Version: "TLSv1.3",
+		CN:      "client.example.com",
+		Cipher:  "TLS_AES_128_GCM_SHA256",
+	}
+	headers := []Header{
+		{
+			Version:         V2,
+			Command:         Proxy,
+			Protocol:        Stream,
+			Source:          src,
+			Destination:     dst,
+			SourcePort:      4711,
+			DestinationPort: 443,
+			TLVs: []TLV{
+				{Type: TypeALPN, Value: []byte("h2")},
+				{Type: TypeAuthority, Value: []byte("example.com")},
+				{Type: TypeUniqueID, Value: []byte{1, 2, 3, 4}},
+				ssl.TLV(),
+				{Type: TypeCRC32C},
+			},
+		},
+		{
+			Version:         V2,
+			Command:         Proxy,
+			Protocol:        Datagram,
+			Source:          ipaddr.IPv4(192, 0, 2, 1),
+			Destination:     ipaddr.IPv4(198, 51, 100, 1),
+			SourcePort:      5353,
+			DestinationPort: 53,
+		},
+		{
+			Version: V2,
+			Command: Local,
+		},
+		{
+			Version:         V1,
+			Command:         Proxy,
+			Protocol:        Stream,
+			Source:          src,
+			Destination:     dst,
+			SourcePort:      4711,
+			DestinationPort: 443,
+		},
+	}
+	for i, h := range headers {
+		var buf bytes.Buffer
+		if _, err := h.WriteTo(&buf); err != nil {
+			t.Errorf("%d: fail to write header: %s", i, err)
+			continue
+		}
+		got, err := Read(bufio.NewReader(&buf))
+		if err != nil {
+			t.Errorf("%d: fail to read header: %s", i, err)
+			continue
+		}
+		if got.Version != h.Version || got.Command != h.Command || got.Protocol != h.Protocol {
+			t.Errorf("%d: header mismatched! want %+v, got %+v", i, h, got)
+		}
+		if !got.Source.Equal(h.Source) || !got.Destination.Equal(h.Destination) {
+			t.Errorf("%d: addresses mismatched! got %s -> %s", i, got.Source, got.Destination)
+		}
+		if got.SourcePort != h.SourcePort || got.DestinationPort != h.DestinationPort {
+			t.Errorf("%d: ports mismatched! got %d -> %d", i, got.SourcePort, got.DestinationPort)
+		}
+		if len(got.TLVs) != len(h.TLVs) {
+			t.Errorf("%d: tlvs mismatched! want %d, got %d", i, len(h.TLVs), len(got.TLVs))
+		}
+	}
+
+	var buf bytes.Buffer
+	headers[0].WriteTo(&buf)
+	h, err := Read(bufio.NewReader(bytes.NewReader(buf.Bytes())))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if h.ALPN() != "h2" || h.Authority() != "example.com" || !bytes.Equal(h.UniqueID(), []byte{1, 2, 3, 4}) {
+		t.Errorf("tlvs mismatched! got %q, %q, %x", h.ALPN(), h.Authority(), h.UniqueID())
+	}
+	s, err := h.SSL()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if s != ssl || !s.Verified() {
+		t.Errorf("ssl mismatched! want %+v, got %+v", ssl, s)
+	}
+
+	corrupted := buf.Bytes()
+	corrupted[20]++
+	if _, err := Read(bufio.NewReader(bytes.NewReader(corrupted))); !errors.Is(err, ErrChecksum) {
+		t.Errorf("expected ErrChecksum, got %v", err)
+	}
+}
+
+func TestWriteInvalid(t *testing.T) {
+	headers := []Header{
+		{Version: 3},
+		{Version: V1, Command: Proxy, Protocol: Datagram, Source: ipaddr.IPv4(192, 0, 2, 1), Destination: ipaddr.IPv4(192, 0, 2, 2)},
+		{Version: V2, Command: Proxy, Protocol: Stream, Source: ipaddr.IPv4(192, 0, 2, 1)},
+	}
+	for i, h := range headers {
+		if _, err := h.WriteTo(&bytes.Buffer{}); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%d: expected ErrInvalid, got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package proxyproto
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/midbel/ipaddr"
+)
+
+const DefaultTimeout = 5 * time.Second
+
+var ErrUntrusted = errors.New("proxyproto: header sent by untrusted peer")
+
+// Listener wraps a net.Listener and reads the PROXY header sent by the peers
+// in Trusted. The connections of the other peers are given as is to the
+// application, unless they start with a PROXY header: such connections are
+// closed and reading from them fails with ErrUntrusted.
+type Listener struct {
+	net.Listener
+	Trusted []ipaddr.Net
+	// Timeout limits the time given to a trusted peer to send its header.
+	Timeout time.Duration
+}
+
+func Listen(inner net.Listener, trusted ...ipaddr.Net) *Listener {
+	return &Listener{
+		Listener: inner,
+		Trusted:  trusted,
+		Timeout:  DefaultTimeout,
+	}
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	conn := Conn{
+		Conn:    c,
+		reader:  bufio.NewReader(c),
+		timeout: l.Timeout,
+	}
+	conn.trusted = l.isTrusted(c.RemoteAddr())
+	return &conn, nil
+}
+
+func (l *Listener) isTrusted(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	ip, err := ipaddr.FromStdIP(tcp.IP)
+	if err != nil {
+		return false
+	}
+	for _, nw := range l.Trusted {
+		if nw.Address().Is6() == ip.Is6() && nw.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is a connection accepted by a Listener. The header is read on the
+// first call to Read, Header, LocalAddr or RemoteAddr: for a trusted peer,
+// LocalAddr and RemoteAddr block until the header is received or the timeout
+// of the Listener expires.
+type Conn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	trusted bool
+
+	once   sync.Once
+	header *Header
+	err    error
+
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+// Header returns the header sent by the peer or nil if the peer is not
+// trusted. For an untrusted peer, it waits for enough data to tell whether the
+// connection starts with a PROXY header.
+func (c *Conn) Header() (*Header, error) {
+	c.once.Do(c.readHeader)
+	return c.header, c.err
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	if _, err := c.Header(); err != nil {
+		return 0, err
+	}
+	return c.reader.Read(b)
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	if !c.trusted {
+		return c.Conn.RemoteAddr()
+	}
+	h, err := c.Header()
+	if err != nil || h == nil || h.Command != Proxy || h.Source.IsUndefined() {
+		return c.Conn.RemoteAddr()
+	}
+	return makeAddr(h.Protocol, h.Source, h.SourcePort)
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	if !c.trusted {
+		return c.Conn.LocalAddr()
+	}
+	h, err := c.Header()
+	if err != nil || h == nil || h.Command != Proxy || h.Destination.IsUndefined() {
+		return c.Conn.LocalAddr()
+	}
+	return makeAddr(h.Protocol, h.Destination, h.DestinationPort)
+}
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return c.Conn.SetReadDeadline(t)
+}
+
+// readHeader applies the timeout of the listener while reading the header
+// unless the application set an earlier deadline, and restores the deadline
+// of the application afterwards.
+func (c *Conn) readHeader() {
+	if !c.trusted {
+		if hasSignature(c.reader) {
+			c.err = ErrUntrusted
+			c.Conn.Close()
+		}
+		return
+	}
+	if c.timeout > 0 {
+		c.mu.Lock()
+		deadline := time.Now().Add(c.timeout)
+		if !c.deadline.IsZero() && c.deadline.Before(deadline) {
+			deadline = c.deadline
+		}
+		c.Conn.SetReadDeadline(deadline)
+		c.mu.Unlock()
+		defer func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.Conn.SetReadDeadline(c.deadline)
+		}()
+	}
+	c.header, c.err = Read(c.reader)
+}
+
+// hasSignature peeks at the data of the connection as long as it could still
+// be the signature of a PROXY header.
+func hasSignature(r *bufio.Reader) bool {
+	for n := 1; ; n++ {
+		b, err := r.Peek(n)
+		if err != nil {
+			return false
+		}
+		str := string(b)
+		switch {
+		case str == signatureV1 || str == signatureV2:
+			return true
+		case !strings.HasPrefix(signatureV1, str) && !strings.HasPrefix(signatureV2, str):
+			return false
+		}
+	}
+}
+
+func makeAddr(proto Protocol, ip ipaddr.IP, port uint16) net.Addr {
+	if proto == Datagram {
+		return &net.UDPAddr{IP: ip.ToStdIP(), Port: int(port)}
+	}
+	return &net.TCPAddr{IP: ip.ToStdIP(), Port: int(port)}
+}
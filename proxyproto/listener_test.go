@@ -0,0 +1,144 @@
+package proxyproto
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/midbel/ipaddr"
+)
+
+func TestListener(t *testing.T) {
+	data := []struct {
+		Trusted string
+		Send    string
+		Remote  string
+		Payload string
+		Header  bool
+		Err     error
+	}{
+		{
+			Trusted: "127.0.0.0/8",
+			Send:    "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nhello",
+			Remote:  "192.0.2.1:56324",
+			Payload: "hello",
+			Header:  true,
+		},
+		{
+			Trusted: "::/0",
+			Send:    "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nhello",
+			Err:     ErrUntrusted,
+		},
+		{
+			Trusted: "192.0.2.0/24",
+			Send:    "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nhello",
+			Err:     ErrUntrusted,
+		},
+		{
+			Trusted: "192.0.2.0/24",
+			Send:    signatureV2 + "\x21\x11\x00\x00",
+			Err:     ErrUntrusted,
+		},
+		{
+			Trusted: "192.0.2.0/24",
+			Send:    "PROXIMITY",
+			Payload: "PROXIMITY",
+		},
+		{
+			Trusted: "192.0.2.0/24",
+			Send:    "\r\n",
+			Payload: "\r\n",
+		},
+	}
+	for _, d := range data {
+		trusted, _ := ipaddr.ParseNet(d.Trusted)
+		inner, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			t.Skipf("can not listen: %s", err)
+		}
+		ln := Listen(inner, trusted)
+
+		go func() {
+			c, err := net.Dial("tcp4", inner.Addr().String())
+			if err != nil {
+				return
+			}
+			c.Write([]byte(d.Send))
+			c.Close()
+		}()
+
+		c, err := ln.Accept()
+		if err != nil {
+			t.Fatalf("fail to accept: %s", err)
+		}
+		payload, err := ioutil.ReadAll(c)
+		if d.Err != nil {
+			if !errors.Is(err, d.Err) {
+				t.Errorf("%s: expected error %v, got %v", d.Trusted, d.Err, err)
+			}
+			c.Close()
+			ln.Close()
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", d.Trusted, err)
+		}
+		if string(payload) != d.Payload {
+			t.Errorf("%s: payload mismatched! want %q, got %q", d.Trusted, d.Payload, payload)
+		}
+		h, _ := c.(*Conn).Header()
+		if (h != nil) != d.Header {
+			t.Errorf("%s: header mismatched! got %+v", d.Trusted, h)
+		}
+		if d.Remote != "" && c.RemoteAddr().String() != d.Remote {
+			t.Errorf("%s: remote address mismatched! want %s, got %s", d.Trusted, d.Remote, c.RemoteAddr())
+		}
+		if d.Remote == "" && c.RemoteAddr().String() == "192.0.2.1:56324" {
+			t.Errorf("%s: header of untrusted peer should be ignored", d.Trusted)
+		}
+		c.Close()
+		ln.Close()
+	}
+}
+
+func TestListenerDeadline(t *testing.T) {
+	inner, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("can not listen: %s", err)
+	}
+	defer inner.Close()
+
+	loopback, _ := ipaddr.ParseNet("127.0.0.0/8")
+	ln := Listen(inner, loopback)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		c, err := net.Dial("tcp4", inner.Addr().String())
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		c.Write([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"))
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+		}
+	}()
+
+	c, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("fail to accept: %s", err)
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if h, err := c.(*Conn).Header(); err != nil || h == nil {
+		t.Fatalf("fail to read header: %v", err)
+	}
+	if _, err := c.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
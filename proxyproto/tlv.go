@@ -0,0 +1,157 @@
+package proxyproto
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	TypeALPN      = 0x01
+	TypeAuthority = 0x02
+	TypeCRC32C    = 0x03
+	TypeNoop      = 0x04
+	TypeUniqueID  = 0x05
+	TypeSSL       = 0x20
+	TypeNetNS     = 0x30
+)
+
+const (
+	subtypeSSLVersion = 0x21
+	subtypeSSLCN      = 0x22
+	subtypeSSLCipher  = 0x23
+	subtypeSSLSigAlg  = 0x24
+	subtypeSSLKeyAlg  = 0x25
+)
+
+const (
+	ClientSSL      = 0x01
+	ClientCertConn = 0x02
+	ClientCertSess = 0x04
+)
+
+const maxUniqueID = 128
+
+type TLV struct {
+	Type  uint8
+	Value []byte
+}
+
+func parseTLVs(b []byte) ([]TLV, error) {
+	var list []TLV
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return nil, fmt.Errorf("%w: tlv truncated", ErrInvalid)
+		}
+		size := int(binary.BigEndian.Uint16(b[1:]))
+		if len(b) < 3+size {
+			return nil, fmt.Errorf("%w: tlv %#x truncated", ErrInvalid, b[0])
+		}
+		t := TLV{
+			Type:  b[0],
+			Value: b[3 : 3+size],
+		}
+		if t.Type == TypeUniqueID && size > maxUniqueID {
+			return nil, fmt.Errorf("%w: unique id too long (%d bytes)", ErrInvalid, size)
+		}
+		list, b = append(list, t), b[3+size:]
+	}
+	return list, nil
+}
+
+func (h *Header) Get(typ uint8) ([]byte, bool) {
+	for _, t := range h.TLVs {
+		if t.Type == typ {
+			return t.Value, true
+		}
+	}
+	return nil, false
+}
+
+func (h *Header) ALPN() string {
+	v, _ := h.Get(TypeALPN)
+	return string(v)
+}
+
+func (h *Header) Authority() string {
+	v, _ := h.Get(TypeAuthority)
+	return string(v)
+}
+
+func (h *Header) UniqueID() []byte {
+	v, _ := h.Get(TypeUniqueID)
+	return v
+}
+
+func (h *Header) SSL() (SSL, error) {
+	v, ok := h.Get(TypeSSL)
+	if !ok {
+		return SSL{}, fmt.Errorf("%w: no ssl tlv", ErrInvalid)
+	}
+	return parseSSL(v)
+}
+
+type SSL struct {
+	Client  uint8
+	Verify  uint32
+	Version string
+	CN      string
+	Cipher  string
+	SigAlg  string
+	KeyAlg  string
+}
+
+// Verified reports whether the client presented a certificate that was
+// successfully verified.
+func (s SSL) Verified() bool {
+	return s.Client&(ClientCertConn|ClientCertSess) != 0 && s.Verify == 0
+}
+
+func (s SSL) TLV() TLV {
+	v := []byte{s.Client, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(v[1:], s.Verify)
+	sub := []struct {
+		typ uint8
+		str string
+	}{
+		{typ: subtypeSSLVersion, str: s.Version},
+		{typ: subtypeSSLCN, str: s.CN},
+		{typ: subtypeSSLCipher, str: s.Cipher},
+		{typ: subtypeSSLSigAlg, str: s.SigAlg},
+		{typ: subtypeSSLKeyAlg, str: s.KeyAlg},
+	}
+	for _, x := range sub {
+		if x.str == "" {
+			continue
+		}
+		v = append(v, x.typ, byte(len(x.str)>>8), byte(len(x.str)))
+		v = append(v, x.str...)
+	}
+	return TLV{Type: TypeSSL, Value: v}
+}
+
+func parseSSL(b []byte) (SSL, error) {
+	var s SSL
+	if len(b) < 5 {
+		return s, fmt.Errorf("%w: ssl tlv truncated", ErrInvalid)
+	}
+	s.Client, s.Verify = b[0], binary.BigEndian.Uint32(b[1:])
+	list, err := parseTLVs(b[5:])
+	if err != nil {
+		return s, err
+	}
+	for _, t := range list {
+		switch t.Type {
+		case subtypeSSLVersion:
+			s.Version = string(t.Value)
+		case subtypeSSLCN:
+			s.CN = string(t.Value)
+		case subtypeSSLCipher:
+			s.Cipher = string(t.Value)
+		case subtypeSSLSigAlg:
+			s.SigAlg = string(t.Value)
+		case subtypeSSLKeyAlg:
+			s.KeyAlg = string(t.Value)
+		}
+	}
+	return s, nil
+}
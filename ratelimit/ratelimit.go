@@ -0,0 +1,189 @@
+// Package ratelimit implements token buckets keyed by the network of the
+// clients rather than by their address, so that a client rotating addresses
+// in its IPv6 prefix still uses a single bucket.
+package ratelimit
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/midbel/ipaddr"
+)
+
+const (
+	DefaultPrefix4 = 32
+	DefaultPrefix6 = 64
+)
+
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+var (
+	Unlimited = Limit{Rate: math.Inf(1)}
+	Blocked   = Limit{}
+)
+
+func Every(interval time.Duration, burst int) Limit {
+	return Limit{
+		Rate:  float64(time.Second) / float64(interval),
+		Burst: burst,
+	}
+}
+
+type bucket struct {
+	key    ipaddr.Net
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps at most Size buckets and evicts the least recently used one
+// when a new client comes in. It is safe for concurrent use.
+type Limiter struct {
+	mu        sync.Mutex
+	limit     Limit
+	size      int
+	prefix4   int
+	prefix6   int
+	overrides ipaddr.Table
+	buckets   map[ipaddr.Net]*list.Element
+	lru       list.List
+}
+
+func New(limit Limit, size int) *Limiter {
+	if size <= 0 {
+		size = 1
+	}
+	return &Limiter{
+		limit:   limit,
+		size:    size,
+		prefix4: DefaultPrefix4,
+		prefix6: DefaultPrefix6,
+		buckets: make(map[ipaddr.Net]*list.Element),
+	}
+}
+
+// Aggregate sets the length of the prefixes used as keys for IPv4 and IPv6
+// clients. The existing buckets are dropped.
+func (l *Limiter) Aggregate(prefix4, prefix6 int) error {
+	if prefix4 < 0 || prefix4 > 32 || prefix6 < 0 || prefix6 > 128 {
+		return fmt.Errorf("invalid aggregation lengths /%d and /%d: %w", prefix4, prefix6, ipaddr.ErrRange)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prefix4, l.prefix6 = prefix4, prefix6
+	l.reset()
+	return nil
+}
+
+// Override gives a specific limit to the clients in nw. When several
+// overrides cover a client, the longest one is used. If nw is longer than the
+// aggregation length, its clients share a bucket keyed by nw. The buckets
+// overlapping nw are dropped.
+func (l *Limiter) Override(nw ipaddr.Net, limit Limit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.overrides.Insert(nw, limit)
+	for key, e := range l.buckets {
+		if overlaps(key, nw) {
+			l.lru.Remove(e)
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+func (l *Limiter) Allow(ip ipaddr.IP) bool {
+	return l.AllowN(ip, time.Now(), 1)
+}
+
+func (l *Limiter) AllowN(ip ipaddr.IP, now time.Time, n int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key, limit, err := l.lookup(ip)
+	if err != nil {
+		return false
+	}
+	switch {
+	case math.IsInf(limit.Rate, 1):
+		return true
+	case n > limit.Burst:
+		return false
+	}
+	b := l.get(key, limit, now)
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed.Seconds()*limit.Rate)
+		b.last = now
+	}
+	if b.tokens < float64(n) {
+		return false
+	}
+	b.tokens -= float64(n)
+	return true
+}
+
+// Key returns the network used as key for the bucket of ip.
+func (l *Limiter) Key(ip ipaddr.IP) (ipaddr.Net, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key, _, err := l.lookup(ip)
+	return key, err
+}
+
+func (l *Limiter) lookup(ip ipaddr.IP) (ipaddr.Net, Limit, error) {
+	ip = ip.Unmap()
+	size := l.prefix6
+	if ip.Is4() {
+		size = l.prefix4
+	}
+	limit := l.limit
+	if nw, v, ok := l.overrides.Lookup(ip); ok {
+		limit = v.(Limit)
+		if nw.Size() > size {
+			return nw, limit, nil
+		}
+	}
+	key, err := ip.Mask(uint8(size))
+	return key, limit, err
+}
+
+func (l *Limiter) get(key ipaddr.Net, limit Limit, now time.Time) *bucket {
+	if e, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(e)
+		return e.Value.(*bucket)
+	}
+	if l.lru.Len() >= l.size {
+		e := l.lru.Back()
+		l.lru.Remove(e)
+		delete(l.buckets, e.Value.(*bucket).key)
+	}
+	b := bucket{
+		key:    key,
+		tokens: float64(limit.Burst),
+		last:   now,
+	}
+	l.buckets[key] = l.lru.PushFront(&b)
+	return &b
+}
+
+func overlaps(a, b ipaddr.Net) bool {
+	if a.Address().Is6() != b.Address().Is6() {
+		return false
+	}
+	return a.Contains(b.Address()) || b.Contains(a.Address())
+}
+
+func (l *Limiter) reset() {
+	l.buckets = make(map[ipaddr.Net]*list.Element)
+	l.lru.Init()
+}
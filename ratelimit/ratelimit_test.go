@@ -0,0 +1,191 @@
+package ratelimit
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/midbel/ipaddr"
+)
+
+func TestLimiter(t *testing.T) {
+	var (
+		l   = New(Every(time.Second, 2), 16)
+		now = time.Unix(1700000000, 0)
+	)
+	data := []struct {
+		Addr  string
+		Delay time.Duration
+		Want  bool
+	}{
+		{Addr: "2001:db8::1", Want: true},
+		{Addr: "2001:db8::2", Want: true},
+		{Addr: "2001:db8::ffff:3", Want: false},
+		{Addr: "2001:db8:0:1::1", Want: true},
+		{Addr: "192.0.2.1", Want: true},
+		{Addr: "192.0.2.1", Want: true},
+		{Addr: "192.0.2.1", Want: false},
+		{Addr: "192.0.2.2", Want: true},
+		{Addr: "2001:db8::4", Delay: time.Second, Want: true},
+		{Addr: "2001:db8::5", Want: false},
+	}
+	for i, d := range data {
+		ip, _ := ipaddr.ParseIP(d.Addr)
+		now = now.Add(d.Delay)
+		if got := l.AllowN(ip, now, 1); got != d.Want {
+			t.Errorf("%d/%s: result mismatched! want %t, got %t", i, d.Addr, d.Want, got)
+		}
+	}
+	if l.Len() != 4 {
+		t.Errorf("buckets count mismatched! want 4, got %d", l.Len())
+	}
+}
+
+func TestLimiterAggregate(t *testing.T) {
+	l := New(Every(time.Second, 1), 16)
+	if err := l.Aggregate(24, 48); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := l.Aggregate(33, 48); !errors.Is(err, ipaddr.ErrRange) {
+		t.Errorf("expected ErrRange, got %v", err)
+	}
+	data := []struct {
+		Addr string
+		Key  string
+	}{
+		{Addr: "192.0.2.42", Key: "192.0.2.0/24"},
+		{Addr: "::ffff:192.0.2.42", Key: "192.0.2.0/24"},
+		{Addr: "::ffff:198.51.100.1", Key: "198.51.100.0/24"},
+		{Addr: "2001:db8:1:2::1", Key: "2001:db8:1::/48"},
+	}
+	for _, d := range data {
+		ip, _ := ipaddr.ParseIP(d.Addr)
+		key, err := l.Key(ip)
+		if err != nil || key.String() != d.Key {
+			t.Errorf("%s: key mismatched! want %s, got %s (%v)", d.Addr, d.Key, key, err)
+		}
+	}
+}
+
+func TestLimiterOverride(t *testing.T) {
+	var (
+		l   = New(Every(time.Second, 1), 16)
+		now = time.Unix(1700000000, 0)
+	)
+	for _, o := range []struct {
+		Net   string
+		Limit Limit
+	}{
+		{Net: "10.0.0.0/8", Limit: Unlimited},
+		{Net: "10.1.0.0/16", Limit: Blocked},
+		{Net: "2001:db8::/32", Limit: Limit{Rate: 1, Burst: 3}},
+		{Net: "2001:db8::1/128", Limit: Blocked},
+	} {
+		nw, _ := ipaddr.ParseNet(o.Net)
+		l.Override(nw, o.Limit)
+	}
+	data := []struct {
+		Addr  string
+		Count int
+		Key   string
+	}{
+		{Addr: "10.2.3.4", Count: 100, Key: "10.2.3.4/32"},
+		{Addr: "10.1.2.3", Count: 0, Key: "10.1.2.3/32"},
+		{Addr: "192.0.2.1", Count: 1, Key: "192.0.2.1/32"},
+		{Addr: "2001:db8::2", Count: 3, Key: "2001:db8::/64"},
+		{Addr: "2001:db8::1", Count: 0, Key: "2001:db8::1/128"},
+	}
+	for _, d := range data {
+		ip, _ := ipaddr.ParseIP(d.Addr)
+		var count int
+		for i := 0; i < 100; i++ {
+			if l.AllowN(ip, now, 1) {
+				count++
+			}
+		}
+		if count != d.Count {
+			t.Errorf("%s: allowed count mismatched! want %d, got %d", d.Addr, d.Count, count)
+		}
+		if key, _ := l.Key(ip); key.String() != d.Key {
+			t.Errorf("%s: key mismatched! want %s, got %s", d.Addr, d.Key, key)
+		}
+	}
+}
+
+func TestLimiterOverrideBuckets(t *testing.T) {
+	var (
+		l   = New(Every(time.Hour, 1), 16)
+		now = time.Unix(1700000000, 0)
+	)
+	for _, str := range []string{"192.0.2.1", "192.0.2.2", "198.51.100.1", "2001:db8::1"} {
+		ip, _ := ipaddr.ParseIP(str)
+		l.AllowN(ip, now, 1)
+	}
+	nw, _ := ipaddr.ParseNet("192.0.2.0/24")
+	l.Override(nw, Limit{Rate: 1, Burst: 2})
+	if l.Len() != 2 {
+		t.Errorf("buckets count mismatched! want 2, got %d", l.Len())
+	}
+	nw, _ = ipaddr.ParseNet("::/0")
+	l.Override(nw, Unlimited)
+	if l.Len() != 1 {
+		t.Errorf("buckets count mismatched! want 1, got %d", l.Len())
+	}
+	ip, _ := ipaddr.ParseIP("198.51.100.1")
+	if l.AllowN(ip, now, 1) {
+		t.Errorf("%s: bucket should have been kept", ip)
+	}
+}
+
+func TestLimiterEviction(t *testing.T) {
+	var (
+		l   = New(Every(time.Hour, 1), 2)
+		now = time.Unix(1700000000, 0)
+		a   = ipaddr.IPv4(192, 0, 2, 1)
+		b   = ipaddr.IPv4(192, 0, 2, 2)
+		c   = ipaddr.IPv4(192, 0, 2, 3)
+	)
+	l.AllowN(a, now, 1)
+	l.AllowN(b, now, 1)
+	if l.AllowN(a, now, 1) {
+		t.Fatalf("%s: bucket should be empty", a)
+	}
+	l.AllowN(c, now, 1)
+	if l.Len() != 2 {
+		t.Errorf("buckets count mismatched! want 2, got %d", l.Len())
+	}
+	if !l.AllowN(b, now, 1) {
+		t.Errorf("%s: least recently used bucket should have been evicted", b)
+	}
+	if l.AllowN(c, now, 1) {
+		t.Errorf("%s: bucket should be empty", c)
+	}
+}
+
+func TestLimiterConcurrent(t *testing.T) {
+	var (
+		l       = New(Every(time.Hour, 10), 8)
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	ip := ipaddr.IPv6(0x2001, 0xdb8, 0, 0, 0, 0, 0, 1)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if l.Allow(ip) {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 10 {
+		t.Errorf("allowed count mismatched! want 10, got %d", allowed)
+	}
+}
@@ -0,0 +1,281 @@
+// Package realip finds the address of the client of an HTTP request sent
+// through a chain of reverse proxies. The forwarding header is walked from
+// right to left and the first hop not in the trusted networks is returned.
+// Only the addresses added by trusted proxies are considered: everything at
+// the left of the first untrusted hop is under the control of the client.
+package realip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/midbel/ipaddr"
+)
+
+var (
+	ErrMalformed  = errors.New("realip: malformed header")
+	ErrObfuscated = errors.New("realip: obfuscated client identifier")
+)
+
+const (
+	HeaderForwarded     = "Forwarded"
+	HeaderForwardedFor  = "X-Forwarded-For"
+	HeaderRealIP        = "X-Real-IP"
+	identifierUnknown   = "unknown"
+	identifierObfuscate = '_'
+)
+
+// Resolver trusts a single forwarding header: the one written by its trusted
+// proxies. Any other forwarding header is ignored since it may have been set
+// by the client. When Header is empty, the remote address of the request is
+// returned.
+type Resolver struct {
+	Trusted []ipaddr.Net
+	Header  string
+}
+
+func New(header string, trusted ...ipaddr.Net) *Resolver {
+	return &Resolver{
+		Trusted: trusted,
+		Header:  header,
+	}
+}
+
+func (r *Resolver) Resolve(req *http.Request) (ipaddr.IP, error) {
+	remote, err := parseNode(req.RemoteAddr)
+	if err != nil {
+		return ipaddr.Zero, fmt.Errorf("%s: invalid remote address: %w", req.RemoteAddr, err)
+	}
+	if !r.IsTrusted(remote) || r.Header == "" {
+		return remote, nil
+	}
+	values := req.Header.Values(r.Header)
+	if len(values) == 0 {
+		return remote, nil
+	}
+	var hops []string
+	switch http.CanonicalHeaderKey(r.Header) {
+	case HeaderForwarded:
+		hops, err = splitForwarded(values)
+	case http.CanonicalHeaderKey(HeaderRealIP):
+		if len(values) > 1 {
+			return ipaddr.Zero, fmt.Errorf("%w: %s: multiple values", ErrMalformed, r.Header)
+		}
+		hops = []string{strings.TrimSpace(values[0])}
+	default:
+		hops, err = splitList(values)
+	}
+	if err != nil {
+		return ipaddr.Zero, fmt.Errorf("%s: %w", r.Header, err)
+	}
+	return r.walk(hops, remote)
+}
+
+func (r *Resolver) IsTrusted(ip ipaddr.IP) bool {
+	for _, nw := range r.Trusted {
+		if nw.Address().Is6() == ip.Is6() && nw.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Resolver) walk(hops []string, last ipaddr.IP) (ipaddr.IP, error) {
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, err := parseNode(hops[i])
+		if err != nil {
+			return ipaddr.Zero, err
+		}
+		if !r.IsTrusted(ip) {
+			return ip, nil
+		}
+		last = ip
+	}
+	return last, nil
+}
+
+type ctxKey struct{}
+
+// Handler stores the address of the client in the context of the request
+// given to next. Requests with malformed headers are rejected.
+func (r *Resolver) Handler(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ip, err := r.Resolve(req)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		ctx := context.WithValue(req.Context(), ctxKey{}, ip)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+func FromContext(ctx context.Context) (ipaddr.IP, bool) {
+	ip, ok := ctx.Value(ctxKey{}).(ipaddr.IP)
+	return ip, ok
+}
+
+func splitList(values []string) ([]string, error) {
+	var hops []string
+	for _, v := range values {
+		for _, h := range strings.Split(v, ",") {
+			h = strings.TrimSpace(h)
+			if h == "" {
+				return nil, fmt.Errorf("%w: empty element", ErrMalformed)
+			}
+			hops = append(hops, h)
+		}
+	}
+	return hops, nil
+}
+
+// splitForwarded extracts the for parameter of each element of the Forwarded
+// headers (RFC 7239).
+func splitForwarded(values []string) ([]string, error) {
+	var hops []string
+	for _, v := range values {
+		for len(v) > 0 {
+			var (
+				node string
+				seen bool
+			)
+			for {
+				var (
+					name, value string
+					err         error
+				)
+				if name, value, v, err = scanPair(v); err != nil {
+					return nil, err
+				}
+				if strings.EqualFold(name, "for") {
+					if seen {
+						return nil, fmt.Errorf("%w: duplicate for parameter", ErrMalformed)
+					}
+					node, seen = value, true
+				}
+				v = strings.TrimLeft(v, " \t")
+				if v == "" || v[0] == ',' {
+					break
+				}
+				if v[0] != ';' {
+					return nil, fmt.Errorf("%w: unexpected character %q", ErrMalformed, v[0])
+				}
+				v = strings.TrimLeft(v[1:], " \t")
+			}
+			if !seen {
+				return nil, fmt.Errorf("%w: missing for parameter", ErrMalformed)
+			}
+			hops = append(hops, node)
+			if v != "" {
+				v = strings.TrimLeft(v[1:], " \t")
+				if v == "" {
+					return nil, fmt.Errorf("%w: empty element", ErrMalformed)
+				}
+			}
+		}
+	}
+	return hops, nil
+}
+
+func scanPair(str string) (string, string, string, error) {
+	x := strings.IndexByte(str, '=')
+	if x <= 0 || !isToken(str[:x]) {
+		return "", "", "", fmt.Errorf("%w: invalid parameter name", ErrMalformed)
+	}
+	name, str := str[:x], str[x+1:]
+	if str == "" {
+		return "", "", "", fmt.Errorf("%w: %s: missing value", ErrMalformed, name)
+	}
+	if str[0] != '"' {
+		x = strings.IndexAny(str, ";, \t")
+		if x < 0 {
+			x = len(str)
+		}
+		if x == 0 || !isToken(str[:x]) {
+			return "", "", "", fmt.Errorf("%w: %s: invalid value", ErrMalformed, name)
+		}
+		return name, str[:x], str[x:], nil
+	}
+	var value strings.Builder
+	for i := 1; i < len(str); i++ {
+		switch c := str[i]; c {
+		case '"':
+			return name, value.String(), str[i+1:], nil
+		case '\\':
+			if i++; i >= len(str) {
+				break
+			}
+			value.WriteByte(str[i])
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return "", "", "", fmt.Errorf("%w: %s: unterminated quoted string", ErrMalformed, name)
+}
+
+func isToken(str string) bool {
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte("\"(),/:;<=>?@[\\]{}", c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// parseNode parses a node identifier: an IPv4 address or an IPv6 address
+// optionally enclosed in brackets, both optionally followed by a port when
+// written as host:port or [host]:port.
+func parseNode(str string) (ipaddr.IP, error) {
+	if str == identifierUnknown || (str != "" && str[0] == identifierObfuscate) {
+		return ipaddr.Zero, fmt.Errorf("%w: %q", ErrObfuscated, str)
+	}
+	host := str
+	switch {
+	case strings.HasPrefix(str, "["):
+		x := strings.IndexByte(str, ']')
+		if x < 0 {
+			return ipaddr.Zero, fmt.Errorf("%w: %q: missing closing bracket", ErrMalformed, str)
+		}
+		if rest := str[x+1:]; rest != "" {
+			if err := checkPort(rest); err != nil {
+				return ipaddr.Zero, fmt.Errorf("%w: %q", err, str)
+			}
+		}
+		host = str[1:x]
+	case strings.Count(str, ":") == 1:
+		x := strings.IndexByte(str, ':')
+		if err := checkPort(str[x:]); err != nil {
+			return ipaddr.Zero, fmt.Errorf("%w: %q", err, str)
+		}
+		host = str[:x]
+	}
+	ip, err := ipaddr.ParseIP(host)
+	if err != nil {
+		return ipaddr.Zero, fmt.Errorf("%w: %q: %s", ErrMalformed, str, err)
+	}
+	return ip.Unmap(), nil
+}
+
+func checkPort(str string) error {
+	if len(str) < 2 || str[0] != ':' {
+		return ErrMalformed
+	}
+	str = str[1:]
+	if str[0] == identifierObfuscate {
+		return nil
+	}
+	if len(str) > 5 {
+		return ErrMalformed
+	}
+	for i := 0; i < len(str); i++ {
+		if str[i] < '0' || str[i] > '9' {
+			return ErrMalformed
+		}
+	}
+	return nil
+}
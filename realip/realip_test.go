@@ -0,0 +1,254 @@
+package realip
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/midbel/ipaddr"
+)
+
+func TestResolve(t *testing.T) {
+	trusted := parseNets(t, "10.0.0.0/8", "2001:db8:ffff::/48")
+	data := []struct {
+		Remote  string
+		Header  string
+		Headers map[string][]string
+		Want    string
+		Err     error
+	}{
+		{
+			Remote:  "192.0.2.1:4711",
+			Header:  HeaderForwardedFor,
+			Headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			Want:    "192.0.2.1",
+		},
+		{
+			Remote: "10.0.0.1:4711",
+			Header: HeaderForwardedFor,
+			Want:   "10.0.0.1",
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderForwardedFor,
+			Headers: map[string][]string{"X-Forwarded-For": {"203.0.113.7, 198.51.100.1, 10.1.1.1"}},
+			Want:    "198.51.100.1",
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderForwardedFor,
+			Headers: map[string][]string{"X-Forwarded-For": {"203.0.113.7", "198.51.100.1:8080, 10.1.1.1"}},
+			Want:    "198.51.100.1",
+		},
+		{
+			Remote:  "[2001:db8:ffff::1]:4711",
+			Header:  HeaderForwardedFor,
+			Headers: map[string][]string{"X-Forwarded-For": {"[2001:db8::42]:1234, 10.2.2.2"}},
+			Want:    "2001:db8::42",
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderForwardedFor,
+			Headers: map[string][]string{"X-Forwarded-For": {"10.3.3.3, 10.1.1.1"}},
+			Want:    "10.3.3.3",
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderForwardedFor,
+			Headers: map[string][]string{"X-Forwarded-For": {"::ffff:198.51.100.1"}},
+			Want:    "198.51.100.1",
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderRealIP,
+			Headers: map[string][]string{"X-Real-Ip": {"198.51.100.1"}},
+			Want:    "198.51.100.1",
+		},
+		{
+			Remote: "10.0.0.1:4711",
+			Header: HeaderForwarded,
+			Headers: map[string][]string{
+				"Forwarded":       {`for=192.0.2.43, for="[2001:db8:cafe::17]:4711";proto=https`},
+				"X-Forwarded-For": {"203.0.113.7"},
+			},
+			Want: "2001:db8:cafe::17",
+		},
+		{
+			Remote: "10.0.0.1:4711",
+			Header: HeaderForwardedFor,
+			Headers: map[string][]string{
+				"X-Forwarded-For": {"203.0.113.7"},
+				"Forwarded":       {"for=6.6.6.6"},
+			},
+			Want: "203.0.113.7",
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderForwarded,
+			Headers: map[string][]string{"X-Forwarded-For": {"6.6.6.6"}},
+			Want:    "10.0.0.1",
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Headers: map[string][]string{"X-Forwarded-For": {"6.6.6.6"}},
+			Want:    "10.0.0.1",
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderForwarded,
+			Headers: map[string][]string{"Forwarded": {`for=198.51.100.17;by=203.0.113.60;proto=http;host=example.com`, `For="10.1.1.1"`}},
+			Want:    "198.51.100.17",
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderForwarded,
+			Headers: map[string][]string{"Forwarded": {`for=_hidden, for=10.1.1.1`}},
+			Err:     ErrObfuscated,
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderForwarded,
+			Headers: map[string][]string{"Forwarded": {`for=198.51.100.1, for=unknown`}},
+			Err:     ErrObfuscated,
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderForwarded,
+			Headers: map[string][]string{"Forwarded": {`for="[2001:db8::1]:_port"`}},
+			Want:    "2001:db8::1",
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderForwarded,
+			Headers: map[string][]string{"Forwarded": {`for=2001:db8::1`}},
+			Err:     ErrMalformed,
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderForwarded,
+			Headers: map[string][]string{"Forwarded": {`for="198.51.100.1`}},
+			Err:     ErrMalformed,
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderForwarded,
+			Headers: map[string][]string{"Forwarded": {`proto=https`}},
+			Err:     ErrMalformed,
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderForwarded,
+			Headers: map[string][]string{"Forwarded": {`for=198.51.100.1,`}},
+			Err:     ErrMalformed,
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderForwardedFor,
+			Headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1,,10.1.1.1"}},
+			Err:     ErrMalformed,
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderForwardedFor,
+			Headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1:http"}},
+			Err:     ErrMalformed,
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderForwardedFor,
+			Headers: map[string][]string{"X-Forwarded-For": {"example.com"}},
+			Err:     ErrMalformed,
+		},
+		{
+			Remote:  "10.0.0.1:4711",
+			Header:  HeaderRealIP,
+			Headers: map[string][]string{"X-Real-Ip": {"198.51.100.1", "198.51.100.2"}},
+			Err:     ErrMalformed,
+		},
+	}
+	for i, d := range data {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = d.Remote
+		for k, vs := range d.Headers {
+			for _, v := range vs {
+				req.Header.Add(k, v)
+			}
+		}
+		got, err := New(d.Header, trusted...).Resolve(req)
+		if d.Err != nil {
+			if !errors.Is(err, d.Err) {
+				t.Errorf("%d: expected error %v, got %v (%s)", i, d.Err, err, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error: %s", i, err)
+			continue
+		}
+		if got.String() != d.Want {
+			t.Errorf("%d: address mismatched! want %s, got %s", i, d.Want, got)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var (
+		r    = New(HeaderForwardedFor, parseNets(t, "10.0.0.0/8")...)
+		seen ipaddr.IP
+	)
+	h := r.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		seen, _ = FromContext(req.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:4711"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || seen.String() != "198.51.100.1" {
+		t.Errorf("handler mismatched! got %d with %s", rec.Code, seen)
+	}
+
+	req.Header.Set("X-Forwarded-For", "not-an-ip")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status mismatched! want %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestIsTrusted(t *testing.T) {
+	data := []struct {
+		Trusted string
+		Addr    string
+		Want    bool
+	}{
+		{Trusted: "::/0", Addr: "2001:db8::1", Want: true},
+		{Trusted: "::/0", Addr: "192.0.2.1", Want: false},
+		{Trusted: "0.0.0.0/0", Addr: "192.0.2.1", Want: true},
+		{Trusted: "192.0.2.0/24", Addr: "::c000:201", Want: false},
+	}
+	for _, d := range data {
+		var (
+			r     = New(HeaderForwardedFor, parseNets(t, d.Trusted)...)
+			ip, _ = ipaddr.ParseIP(d.Addr)
+		)
+		if got := r.IsTrusted(ip); got != d.Want {
+			t.Errorf("%s/%s: result mismatched! want %t, got %t", d.Trusted, d.Addr, d.Want, got)
+		}
+	}
+}
+
+func parseNets(t *testing.T, list ...string) []ipaddr.Net {
+	t.Helper()
+	var nets []ipaddr.Net
+	for _, str := range list {
+		nw, err := ipaddr.ParseNet(str)
+		if err != nil {
+			t.Fatalf("%s: fail to parse: %s", str, err)
+		}
+		nets = append(nets, nw)
+	}
+	return nets
+}
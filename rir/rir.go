@@ -0,0 +1,186 @@
+// Package rir reads the delegated statistics files published daily by the
+// Regional Internet Registries (standard and extended formats) and indexes the
+// address blocks they describe.
+package rir
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/midbel/ipaddr"
+)
+
+const dateLayout = "20060102"
+
+type Record struct {
+	Registry string
+	Country  string
+	Start    ipaddr.IP
+	Nets     []ipaddr.Net
+	Date     time.Time
+	Status   string
+	ID       string
+}
+
+type Reader struct {
+	scan *bufio.Scanner
+	line int
+
+	Version  string
+	Registry string
+	Serial   string
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{scan: bufio.NewScanner(r)}
+}
+
+// Next returns the next IPv4 or IPv6 record of the file. The version line, the
+// summary lines and the asn records are skipped.
+func (r *Reader) Next() (*Record, error) {
+	for r.scan.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scan.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		switch {
+		case len(fields) > 0 && isVersion(fields[0]) && r.Version == "":
+			r.Version = fields[0]
+			if len(fields) > 1 {
+				r.Registry = fields[1]
+			}
+			if len(fields) > 2 {
+				r.Serial = fields[2]
+			}
+			continue
+		case len(fields) >= 6 && fields[5] == "summary":
+			continue
+		case len(fields) < 7:
+			return nil, fmt.Errorf("rir: line %d: not enough fields (%d)", r.line, len(fields))
+		}
+		if fields[2] != "ipv4" && fields[2] != "ipv6" {
+			continue
+		}
+		rec, err := parseRecord(fields)
+		if err != nil {
+			return nil, fmt.Errorf("rir: line %d: %w", r.line, err)
+		}
+		return rec, nil
+	}
+	if err := r.scan.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func parseRecord(fields []string) (*Record, error) {
+	start, err := ipaddr.ParseIP(fields[3])
+	if err != nil {
+		return nil, err
+	}
+	if start.Is6() != (fields[2] == "ipv6") {
+		return nil, fmt.Errorf("%s: not an %s address", fields[3], fields[2])
+	}
+	rec := Record{
+		Registry: fields[0],
+		Country:  strings.ToUpper(fields[1]),
+		Start:    start,
+		Status:   fields[6],
+	}
+	if len(fields) > 7 {
+		rec.ID = fields[7]
+	}
+	if str := fields[5]; str != "" && strings.Trim(str, "0") != "" {
+		if rec.Date, err = time.Parse(dateLayout, str); err != nil {
+			return nil, fmt.Errorf("%s: invalid date", str)
+		}
+	}
+	value, err := strconv.ParseUint(fields[4], 10, 64)
+	if err != nil || value == 0 {
+		return nil, fmt.Errorf("%s: invalid value", fields[4])
+	}
+	if start.Is6() {
+		if value > 128 {
+			return nil, fmt.Errorf("%d: invalid prefix length", value)
+		}
+		nw, err := start.Mask(uint8(value))
+		if err != nil {
+			return nil, err
+		}
+		rec.Nets = []ipaddr.Net{nw}
+		return &rec, nil
+	}
+	first := uint64(binary.BigEndian.Uint32(start.ToStdIP()))
+	if first+value-1 > 1<<32-1 {
+		return nil, fmt.Errorf("%s+%d: range overflows address space", start, value)
+	}
+	var last [4]byte
+	binary.BigEndian.PutUint32(last[:], uint32(first+value-1))
+	if rec.Nets, err = ipaddr.Summarize(start, ipaddr.IPv4(last[0], last[1], last[2], last[3])); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func isVersion(str string) bool {
+	_, err := strconv.ParseFloat(str, 64)
+	return err == nil
+}
+
+// Index answers longest-prefix match queries over the records of one or more
+// delegated files. A zero Index is ready to use but is not safe for concurrent
+// use while records are being added.
+type Index struct {
+	table ipaddr.Table
+	count int
+}
+
+func (x *Index) Load(r io.Reader) error {
+	rs := NewReader(r)
+	for {
+		rec, err := rs.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		x.Add(rec)
+	}
+}
+
+func (x *Index) LoadFile(file string) error {
+	r, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return x.Load(r)
+}
+
+func (x *Index) Len() int {
+	return x.count
+}
+
+func (x *Index) Add(rec *Record) {
+	for _, nw := range rec.Nets {
+		x.table.Insert(nw, rec)
+	}
+	x.count++
+}
+
+func (x *Index) Lookup(ip ipaddr.IP) (*Record, bool) {
+	_, v, ok := x.table.Lookup(ip)
+	if !ok {
+		return nil, false
+	}
+	return v.(*Record), true
+}
@@ -0,0 +1,128 @@
+package rir
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/midbel/ipaddr"
+)
+
+const stats = `# delegated-extended file
+2.3|ripencc|20240101|5|19830705|20231231|+0100
+ripencc|*|asn|*|1|summary
+ripencc|*|ipv4|*|3|summary
+ripencc|*|ipv6|*|1|summary
+ripencc|FR|asn|64500|1|20100101|allocated|abc
+ripencc|FR|ipv4|192.0.2.0|256|20100101|allocated|abc
+ripencc|DE|ipv4|198.51.100.0|384|20150615|assigned|def
+ripencc|nl|ipv4|203.0.113.0|256|00000000|reserved|
+ripencc|BE|ipv6|2001:db8::|32|20200301|allocated|ghi
+`
+
+func TestReader(t *testing.T) {
+	var (
+		r    = NewReader(strings.NewReader(stats))
+		list []*Record
+	)
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		list = append(list, rec)
+	}
+	if r.Version != "2.3" || r.Registry != "ripencc" || r.Serial != "20240101" {
+		t.Errorf("header mismatched! got %s|%s|%s", r.Version, r.Registry, r.Serial)
+	}
+	if len(list) != 4 {
+		t.Fatalf("records count mismatched! want 4, got %d", len(list))
+	}
+	data := []struct {
+		Nets    []string
+		Country string
+		Date    string
+	}{
+		{Nets: []string{"192.0.2.0/24"}, Country: "FR", Date: "2010-01-01"},
+		{Nets: []string{"198.51.100.0/24", "198.51.101.0/25"}, Country: "DE", Date: "2015-06-15"},
+		{Nets: []string{"203.0.113.0/24"}, Country: "NL"},
+		{Nets: []string{"2001:db8::/32"}, Country: "BE", Date: "2020-03-01"},
+	}
+	for i, d := range data {
+		rec := list[i]
+		if rec.Country != d.Country {
+			t.Errorf("%d: country mismatched! want %s, got %s", i, d.Country, rec.Country)
+		}
+		if d.Date == "" && !rec.Date.IsZero() {
+			t.Errorf("%d: date should be zero, got %s", i, rec.Date)
+		} else if d.Date != "" && rec.Date.Format("2006-01-02") != d.Date {
+			t.Errorf("%d: date mismatched! want %s, got %s", i, d.Date, rec.Date)
+		}
+		if len(rec.Nets) != len(d.Nets) {
+			t.Errorf("%d: nets mismatched! want %v, got %v", i, d.Nets, rec.Nets)
+			continue
+		}
+		for j := range d.Nets {
+			if rec.Nets[j].String() != d.Nets[j] {
+				t.Errorf("%d: net mismatched! want %s, got %s", i, d.Nets[j], rec.Nets[j])
+			}
+		}
+	}
+}
+
+func TestReaderInvalid(t *testing.T) {
+	data := []string{
+		"ripencc|FR|ipv4|192.0.2.0|256",
+		"ripencc|FR|ipv4|192.0.2.0|0|20100101|allocated",
+		"ripencc|FR|ipv4|255.255.255.0|512|20100101|allocated",
+		"ripencc|FR|ipv6|192.0.2.0|24|20100101|allocated",
+		"ripencc|FR|ipv6|2001:db8::|129|20100101|allocated",
+		"ripencc|FR|ipv4|192.0.2.0|256|2010|allocated",
+	}
+	for _, str := range data {
+		if _, err := NewReader(strings.NewReader(str)).Next(); err == nil || err == io.EOF {
+			t.Errorf("%s: expected error, got %v", str, err)
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	var x Index
+	if err := x.Load(strings.NewReader(stats)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if x.Len() != 4 {
+		t.Errorf("index length mismatched! want 4, got %d", x.Len())
+	}
+	data := []struct {
+		Addr    string
+		Country string
+		Status  string
+	}{
+		{Addr: "192.0.2.1", Country: "FR", Status: "allocated"},
+		{Addr: "198.51.101.127", Country: "DE", Status: "assigned"},
+		{Addr: "198.51.101.128"},
+		{Addr: "2001:db8:1::1", Country: "BE", Status: "allocated"},
+		{Addr: "2001:db9::1"},
+	}
+	for _, d := range data {
+		ip, _ := ipaddr.ParseIP(d.Addr)
+		rec, ok := x.Lookup(ip)
+		if d.Country == "" {
+			if ok {
+				t.Errorf("%s: unexpected record found (%s)", d.Addr, rec.Country)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%s: no record found", d.Addr)
+			continue
+		}
+		if rec.Country != d.Country || rec.Status != d.Status || rec.Registry != "ripencc" {
+			t.Errorf("%s: record mismatched! got %s/%s/%s", d.Addr, rec.Registry, rec.Country, rec.Status)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// Package rpki implements route origin validation (RFC 6811) against the
+// validated ROA payloads exported in JSON by rpki-client or Routinator.
+package rpki
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/midbel/ipaddr"
+)
+
+type ASN uint32
+
+func ParseASN(str string) (ASN, error) {
+	if len(str) > 2 && strings.EqualFold(str[:2], "AS") {
+		str = str[2:]
+	}
+	n, err := strconv.ParseUint(str, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid AS number", str)
+	}
+	return ASN(n), nil
+}
+
+func (a ASN) String() string {
+	return "AS" + strconv.FormatUint(uint64(a), 10)
+}
+
+func (a *ASN) UnmarshalJSON(b []byte) error {
+	var str string
+	if len(b) > 0 && b[0] == '"' {
+		if err := json.Unmarshal(b, &str); err != nil {
+			return err
+		}
+	} else {
+		str = string(b)
+	}
+	n, err := ParseASN(str)
+	if err == nil {
+		*a = n
+	}
+	return err
+}
+
+type ROA struct {
+	Prefix    ipaddr.Net
+	MaxLength int
+	ASN       ASN
+	TA        string
+}
+
+func (r ROA) String() string {
+	return fmt.Sprintf("%s-%d %s", r.Prefix, r.MaxLength, r.ASN)
+}
+
+func (r *ROA) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Prefix    string `json:"prefix"`
+		MaxLength int    `json:"maxLength"`
+		ASN       ASN    `json:"asn"`
+		TA        string `json:"ta"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	ip, nw, err := ipaddr.ParseCIDR(v.Prefix)
+	if err != nil {
+		return err
+	}
+	if !ip.Equal(nw.Address()) {
+		return fmt.Errorf("%s: host bits set: %w", v.Prefix, ipaddr.ErrInvalid)
+	}
+	if v.MaxLength == 0 {
+		v.MaxLength = nw.Size()
+	}
+	bits := 32
+	if nw.Address().Is6() {
+		bits = 128
+	}
+	if v.MaxLength < nw.Size() || v.MaxLength > bits {
+		return fmt.Errorf("%s: invalid max length %d", v.Prefix, v.MaxLength)
+	}
+	*r = ROA{
+		Prefix:    nw,
+		MaxLength: v.MaxLength,
+		ASN:       v.ASN,
+		TA:        v.TA,
+	}
+	return nil
+}
+
+type State int8
+
+const (
+	NotFound State = iota
+	Valid
+	Invalid
+)
+
+func (s State) String() string {
+	switch s {
+	case NotFound:
+		return "not-found"
+	case Valid:
+		return "valid"
+	case Invalid:
+		return "invalid"
+	default:
+		return "<unknown>"
+	}
+}
+
+type Reason int8
+
+const (
+	ReasonNone Reason = iota
+	ReasonOrigin
+	ReasonLength
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonNone:
+		return ""
+	case ReasonOrigin:
+		return "wrong origin"
+	case ReasonLength:
+		return "too specific"
+	default:
+		return "<unknown>"
+	}
+}
+
+type Result struct {
+	State   State
+	Reason  Reason
+	Covered []ROA
+}
+
+type Validator struct {
+	table ipaddr.Table
+	count int
+}
+
+func Load(r io.Reader) (*Validator, error) {
+	var doc struct {
+		ROAs []ROA `json:"roas"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	var v Validator
+	for _, r := range doc.ROAs {
+		v.Add(r)
+	}
+	return &v, nil
+}
+
+func LoadFile(file string) (*Validator, error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return Load(r)
+}
+
+func (v *Validator) Len() int {
+	return v.count
+}
+
+func (v *Validator) Add(roa ROA) {
+	var list []ROA
+	if x, ok := v.table.Get(roa.Prefix); ok {
+		list = x.([]ROA)
+	}
+	for _, r := range list {
+		if r.ASN == roa.ASN && r.MaxLength == roa.MaxLength {
+			return
+		}
+	}
+	v.table.Insert(roa.Prefix, append(list, roa))
+	v.count++
+}
+
+func (v *Validator) Validate(nw ipaddr.Net, origin ASN) Result {
+	var res Result
+	v.table.Covering(nw, func(_ ipaddr.Net, x interface{}) bool {
+		res.Covered = append(res.Covered, x.([]ROA)...)
+		return true
+	})
+	if len(res.Covered) == 0 {
+		return res
+	}
+	res.State, res.Reason = Invalid, ReasonOrigin
+	for _, r := range res.Covered {
+		if r.ASN != origin || r.ASN == 0 {
+			continue
+		}
+		if nw.Size() <= r.MaxLength {
+			res.State, res.Reason = Valid, ReasonNone
+			break
+		}
+		res.Reason = ReasonLength
+	}
+	return res
+}
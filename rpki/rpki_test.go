@@ -0,0 +1,71 @@
+package rpki
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/midbel/ipaddr"
+)
+
+const roas = `{
+  "metadata": {"generated": 1700000000},
+  "roas": [
+    {"asn": 13335, "prefix": "1.1.1.0/24", "maxLength": 24, "ta": "apnic"},
+    {"asn": "AS64500", "prefix": "192.0.2.0/24", "maxLength": 24, "ta": "ripe"},
+    {"asn": "AS64501", "prefix": "198.51.100.0/22", "maxLength": 24, "ta": "arin"},
+    {"asn": 0, "prefix": "203.0.113.0/24", "maxLength": 24, "ta": "apnic"},
+    {"asn": "AS64502", "prefix": "2001:db8::/32", "maxLength": 48, "ta": "ripe"}
+  ]
+}`
+
+func TestValidate(t *testing.T) {
+	v, err := Load(strings.NewReader(roas))
+	if err != nil {
+		t.Fatalf("fail to load roas: %s", err)
+	}
+	if v.Len() != 5 {
+		t.Fatalf("roas count mismatched! want 5, got %d", v.Len())
+	}
+	data := []struct {
+		Prefix string
+		Origin ASN
+		State  State
+		Reason Reason
+	}{
+		{Prefix: "1.1.1.0/24", Origin: 13335, State: Valid},
+		{Prefix: "1.1.1.0/24", Origin: 64500, State: Invalid, Reason: ReasonOrigin},
+		{Prefix: "192.0.2.128/25", Origin: 64500, State: Invalid, Reason: ReasonLength},
+		{Prefix: "198.51.101.0/24", Origin: 64501, State: Valid},
+		{Prefix: "198.51.101.0/25", Origin: 64501, State: Invalid, Reason: ReasonLength},
+		{Prefix: "203.0.113.0/24", Origin: 0, State: Invalid, Reason: ReasonOrigin},
+		{Prefix: "2001:db8:1::/48", Origin: 64502, State: Valid},
+		{Prefix: "2001:db8:1::/64", Origin: 64502, State: Invalid, Reason: ReasonLength},
+		{Prefix: "10.0.0.0/8", Origin: 64500, State: NotFound},
+	}
+	for _, d := range data {
+		nw, _ := ipaddr.ParseNet(d.Prefix)
+		res := v.Validate(nw, d.Origin)
+		if res.State != d.State || res.Reason != d.Reason {
+			t.Errorf("%s %s: results mismatched! want %s (%s), got %s (%s)", d.Prefix, d.Origin, d.State, d.Reason, res.State, res.Reason)
+		}
+	}
+}
+
+func TestParseASN(t *testing.T) {
+	for _, str := range []string{"AS64500", "as64500", "64500"} {
+		if a, err := ParseASN(str); err != nil || a != 64500 {
+			t.Errorf("%s: results mismatched! want AS64500, got %s (%v)", str, a, err)
+		}
+	}
+	if _, err := ParseASN("ASxyz"); err == nil {
+		t.Errorf("invalid ASN parsed successfully")
+	}
+}
+
+func TestLoadNonCanonical(t *testing.T) {
+	const roas = `{"roas": [{"asn": "AS64500", "prefix": "192.0.2.1/24", "maxLength": 24, "ta": "ripe"}]}`
+	if _, err := Load(strings.NewReader(roas)); !errors.Is(err, ipaddr.ErrInvalid) {
+		t.Errorf("expected ErrInvalid, got %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+package ipaddr
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+var ErrNoSource = errors.New("no suitable source address")
+
+type Policy struct {
+	Net        Net
+	Precedence int
+	Label      int
+}
+
+type PolicyTable []Policy
+
+var DefaultPolicy = PolicyTable{
+	mustPolicy("::1/128", 50, 0),
+	mustPolicy("::/0", 40, 1),
+	mustPolicy("::ffff:0:0/96", 35, 4),
+	mustPolicy("2002::/16", 30, 2),
+	mustPolicy("2001::/32", 5, 5),
+	mustPolicy("fc00::/7", 3, 13),
+	mustPolicy("::/96", 1, 3),
+	mustPolicy("fec0::/10", 1, 11),
+	mustPolicy("3ffe::/16", 1, 12),
+}
+
+func (p PolicyTable) Lookup(ip IP) Policy {
+	var (
+		res  Policy
+		size = -1
+	)
+	ip = ip.To6()
+	for _, e := range p {
+		if e.Net.ip.zone != z6 || !e.Net.Contains(ip) {
+			continue
+		}
+		if n := e.Net.Size(); n > size {
+			res, size = e, n
+		}
+	}
+	return res
+}
+
+func (p PolicyTable) Precedence(ip IP) int {
+	return p.Lookup(ip).Precedence
+}
+
+func (p PolicyTable) Label(ip IP) int {
+	return p.Lookup(ip).Label
+}
+
+func (i IP) Scope() Scope {
+	switch {
+	case i.zone == 0:
+		return ScopeReserved
+	case i.IsMulticast():
+		return i.MulticastScope()
+	case i.IsLoopback() || i.IsLinkLocal():
+		return ScopeLink
+	case i.zone == z6 && uint16(i.set.high>>54) == 0b1111_1110_11:
+		return ScopeSite
+	default:
+		return ScopeGlobal
+	}
+}
+
+// Selector implements RFC 6724. Rules whose predicate is nil are skipped.
+type Selector struct {
+	Policy     PolicyTable
+	Deprecated func(IP) bool
+	Home       func(IP) bool
+	Temporary  func(IP) bool
+}
+
+func SelectSource(dst IP, srcs []IP) (IP, error) {
+	var s Selector
+	return s.SelectSource(dst, srcs)
+}
+
+func SortDestinations(dsts, srcs []IP) {
+	var s Selector
+	s.SortDestinations(dsts, srcs)
+}
+
+func (s Selector) SelectSource(dst IP, srcs []IP) (IP, error) {
+	var (
+		best  IP
+		found bool
+	)
+	for _, ip := range srcs {
+		if ip.zone != dst.zone {
+			continue
+		}
+		if !found || s.preferSource(dst, ip, best) {
+			best, found = ip, true
+		}
+	}
+	if !found {
+		return Zero, fmt.Errorf("%s: %w", dst, ErrNoSource)
+	}
+	return best, nil
+}
+
+func (s Selector) SortDestinations(dsts, srcs []IP) {
+	type candidate struct {
+		dst IP
+		src IP
+		ok  bool
+	}
+	list := make([]candidate, len(dsts))
+	for i := range dsts {
+		src, err := s.SelectSource(dsts[i], srcs)
+		list[i] = candidate{
+			dst: dsts[i],
+			src: src,
+			ok:  err == nil,
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		var (
+			a = list[i]
+			b = list[j]
+		)
+		if a.ok != b.ok {
+			return a.ok
+		}
+		if !a.ok {
+			return false
+		}
+		return s.preferDestination(a.dst, a.src, b.dst, b.src)
+	})
+	for i := range list {
+		dsts[i] = list[i].dst
+	}
+}
+
+func (s Selector) policy() PolicyTable {
+	if len(s.Policy) == 0 {
+		return DefaultPolicy
+	}
+	return s.Policy
+}
+
+func (s Selector) preferSource(dst, a, b IP) bool {
+	if a == b {
+		return false
+	}
+	// rule 1: prefer same address
+	if a == dst {
+		return true
+	}
+	if b == dst {
+		return false
+	}
+	// rule 2: prefer appropriate scope
+	if sa, sb, sd := a.Scope(), b.Scope(), dst.Scope(); sa != sb {
+		if sa < sb {
+			return sa >= sd
+		}
+		return sb < sd
+	}
+	// rule 3: avoid deprecated addresses
+	if ok, prefer := compare(s.Deprecated, b, a); ok {
+		return prefer
+	}
+	// rule 4: prefer home addresses
+	if ok, prefer := compare(s.Home, a, b); ok {
+		return prefer
+	}
+	// rule 6: prefer matching label
+	var (
+		pt = s.policy()
+		ld = pt.Label(dst)
+	)
+	if la, lb := pt.Label(a), pt.Label(b); (la == ld) != (lb == ld) {
+		return la == ld
+	}
+	// rule 7: prefer temporary addresses
+	if ok, prefer := compare(s.Temporary, a, b); ok {
+		return prefer
+	}
+	// rule 8: use longest matching prefix
+	return commonPrefix(a, dst) > commonPrefix(b, dst)
+}
+
+func (s Selector) preferDestination(da, sa, db, sb IP) bool {
+	// rule 2: prefer matching scope
+	if ma, mb := da.Scope() == sa.Scope(), db.Scope() == sb.Scope(); ma != mb {
+		return ma
+	}
+	// rule 3: avoid deprecated addresses
+	if ok, prefer := compare(s.Deprecated, sb, sa); ok {
+		return prefer
+	}
+	// rule 4: prefer home addresses
+	if ok, prefer := compare(s.Home, sa, sb); ok {
+		return prefer
+	}
+	pt := s.policy()
+	// rule 5: prefer matching label
+	if ma, mb := pt.Label(da) == pt.Label(sa), pt.Label(db) == pt.Label(sb); ma != mb {
+		return ma
+	}
+	// rule 6: prefer higher precedence
+	if pa, pb := pt.Precedence(da), pt.Precedence(db); pa != pb {
+		return pa > pb
+	}
+	// rule 7: prefer native transport
+	if na, nb := !da.isTunneled(), !db.isTunneled(); na != nb {
+		return na
+	}
+	// rule 8: prefer smaller scope
+	if ca, cb := da.Scope(), db.Scope(); ca != cb {
+		return ca < cb
+	}
+	// rule 9: use longest matching prefix
+	if da.zone == db.zone {
+		return commonPrefix(da, sa) > commonPrefix(db, sb)
+	}
+	// rule 10: otherwise, leave the order unchanged
+	return false
+}
+
+func (i IP) isTunneled() bool {
+	if i.zone != z6 {
+		return false
+	}
+	return uint16(i.set.high>>48) == 0x2002 || uint32(i.set.high>>32) == 0x2001_0000
+}
+
+func compare(fn func(IP) bool, a, b IP) (bool, bool) {
+	if fn == nil {
+		return false, false
+	}
+	fa, fb := fn(a), fn(b)
+	return fa != fb, fa
+}
+
+func commonPrefix(a, b IP) int {
+	if a.zone != b.zone {
+		return 0
+	}
+	if a.zone == z4 {
+		return bits.LeadingZeros32(uint32(a.set.low ^ b.set.low))
+	}
+	if n := bits.LeadingZeros64(a.set.high ^ b.set.high); n < netmask64 {
+		return n
+	}
+	return netmask64 + bits.LeadingZeros64(a.set.low^b.set.low)
+}
+
+func mustPolicy(str string, precedence, label int) Policy {
+	nw, err := ParseNet(str)
+	if err != nil {
+		panic(err)
+	}
+	return Policy{
+		Net:        nw,
+		Precedence: precedence,
+		Label:      label,
+	}
+}
@@ -0,0 +1,115 @@
+package ipaddr
+
+import (
+	"testing"
+)
+
+func TestSelectSource(t *testing.T) {
+	data := []struct {
+		Dst  string
+		Srcs []string
+		Want string
+	}{
+		{
+			Dst:  "2001:db8:1::1",
+			Srcs: []string{"2001:db8:3::1", "fe80::1"},
+			Want: "2001:db8:3::1",
+		},
+		{
+			Dst:  "ff05::1",
+			Srcs: []string{"2001:db8:3::1", "fe80::1"},
+			Want: "2001:db8:3::1",
+		},
+		{
+			Dst:  "fe80::1",
+			Srcs: []string{"fe80::2", "2001:db8:1::1"},
+			Want: "fe80::2",
+		},
+		{
+			Dst:  "2001:db8:1::1",
+			Srcs: []string{"2001:db8:2::1", "2001:db8:1::1"},
+			Want: "2001:db8:1::1",
+		},
+		{
+			Dst:  "2002:c633:6401::1",
+			Srcs: []string{"2001:db8:1::2", "2002:c633:6401::d5e3:7953:13eb:22e8"},
+			Want: "2002:c633:6401:0:d5e3:7953:13eb:22e8",
+		},
+		{
+			Dst:  "10.1.2.3",
+			Srcs: []string{"2001:db8:1::2", "10.1.2.4"},
+			Want: "10.1.2.4",
+		},
+	}
+	for _, d := range data {
+		dst, _ := ParseIP(d.Dst)
+		got, err := SelectSource(dst, parseList(t, d.Srcs))
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", d.Dst, err)
+			continue
+		}
+		if got.String() != d.Want {
+			t.Errorf("%s: results mismatched! want %s, got %s", d.Dst, d.Want, got)
+		}
+	}
+}
+
+func TestSortDestinations(t *testing.T) {
+	data := []struct {
+		Srcs []string
+		Dsts []string
+		Want []string
+	}{
+		{
+			Srcs: []string{"2001:db8:1::2", "fe80::1", "169.254.13.78"},
+			Dsts: []string{"198.51.100.121", "2001:db8:1::1"},
+			Want: []string{"2001:db8:1::1", "198.51.100.121"},
+		},
+		{
+			Srcs: []string{"fe80::1", "198.51.100.117"},
+			Dsts: []string{"2001:db8:1::1", "198.51.100.121"},
+			Want: []string{"198.51.100.121", "2001:db8:1::1"},
+		},
+		{
+			Srcs: []string{"2001:db8:1::2", "fe80::1", "10.1.2.4"},
+			Dsts: []string{"10.1.2.3", "2001:db8:1::1"},
+			Want: []string{"2001:db8:1::1", "10.1.2.3"},
+		},
+		{
+			Srcs: []string{"2001:db8:1::2", "fe80::2"},
+			Dsts: []string{"2001:db8:1::1", "fe80::1"},
+			Want: []string{"fe80::1", "2001:db8:1::1"},
+		},
+		{
+			Srcs: []string{"2001:db8:1::2"},
+			Dsts: []string{"10.1.2.3", "2001:db8:1::1"},
+			Want: []string{"2001:db8:1::1", "10.1.2.3"},
+		},
+	}
+	for _, d := range data {
+		dsts := parseList(t, d.Dsts)
+		SortDestinations(dsts, parseList(t, d.Srcs))
+		if len(dsts) != len(d.Want) {
+			t.Errorf("%v: length mismatched! want %d, got %d", d.Dsts, len(d.Want), len(dsts))
+			continue
+		}
+		for i := range dsts {
+			if got := dsts[i].String(); got != d.Want[i] {
+				t.Errorf("%v: results mismatched at %d! want %s, got %s", d.Dsts, i, d.Want[i], got)
+			}
+		}
+	}
+}
+
+func parseList(t *testing.T, list []string) []IP {
+	t.Helper()
+	var ips []IP
+	for _, str := range list {
+		ip, err := ParseIP(str)
+		if err != nil {
+			t.Fatalf("%s: fail to parse %s", str, err)
+		}
+		ips = append(ips, ip)
+	}
+	return ips
+}
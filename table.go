@@ -0,0 +1,144 @@
+package ipaddr
+
+// Table maps networks to values and answers longest-prefix match queries. It
+// is backed by a binary trie per address family. A Table is not safe for
+// concurrent use.
+type Table struct {
+	root4 *node
+	root6 *node
+	size  int
+}
+
+type node struct {
+	child [2]*node
+	net   Net
+	value interface{}
+	set   bool
+}
+
+func (t *Table) Len() int {
+	return t.size
+}
+
+func (t *Table) Insert(nw Net, value interface{}) {
+	var (
+		curr = t.root(nw.ip.zone, true)
+		size = nw.mask.ones()
+	)
+	for i := 0; i < size; i++ {
+		b := nw.ip.bit(i)
+		if curr.child[b] == nil {
+			curr.child[b] = &node{}
+		}
+		curr = curr.child[b]
+	}
+	if !curr.set {
+		t.size++
+	}
+	curr.net, curr.value, curr.set = nw, value, true
+}
+
+func (t *Table) Get(nw Net) (interface{}, bool) {
+	n := t.find(nw)
+	if n == nil || !n.set {
+		return nil, false
+	}
+	return n.value, true
+}
+
+func (t *Table) Delete(nw Net) bool {
+	n := t.find(nw)
+	if n == nil || !n.set {
+		return false
+	}
+	n.value, n.set = nil, false
+	t.size--
+	return true
+}
+
+func (t *Table) Lookup(ip IP) (Net, interface{}, bool) {
+	nw, err := ip.Mask(uint8(ip.bitLen()))
+	if err != nil {
+		return Net{}, nil, false
+	}
+	return t.LookupNet(nw)
+}
+
+func (t *Table) LookupNet(nw Net) (Net, interface{}, bool) {
+	var (
+		found Net
+		value interface{}
+		ok    bool
+	)
+	t.Covering(nw, func(n Net, v interface{}) bool {
+		found, value, ok = n, v, true
+		return true
+	})
+	return found, value, ok
+}
+
+func (t *Table) Covering(nw Net, fn func(Net, interface{}) bool) {
+	var (
+		curr = t.root(nw.ip.zone, false)
+		size = nw.mask.ones()
+	)
+	for i := 0; curr != nil; i++ {
+		if curr.set && !fn(curr.net, curr.value) {
+			return
+		}
+		if i >= size {
+			break
+		}
+		curr = curr.child[nw.ip.bit(i)]
+	}
+}
+
+func (t *Table) Walk(fn func(Net, interface{}) bool) {
+	for _, r := range []*node{t.root4, t.root6} {
+		if !r.walk(fn) {
+			return
+		}
+	}
+}
+
+func (n *node) walk(fn func(Net, interface{}) bool) bool {
+	if n == nil {
+		return true
+	}
+	if n.set && !fn(n.net, n.value) {
+		return false
+	}
+	return n.child[0].walk(fn) && n.child[1].walk(fn)
+}
+
+func (t *Table) find(nw Net) *node {
+	var (
+		curr = t.root(nw.ip.zone, false)
+		size = nw.mask.ones()
+	)
+	for i := 0; i < size && curr != nil; i++ {
+		curr = curr.child[nw.ip.bit(i)]
+	}
+	return curr
+}
+
+func (t *Table) root(z zone, create bool) *node {
+	ptr := &t.root4
+	if z == z6 {
+		ptr = &t.root6
+	}
+	if *ptr == nil && create {
+		*ptr = &node{}
+	}
+	return *ptr
+}
+
+func (i IP) bit(n int) int {
+	if i.zone == z4 {
+		return int(i.set.low>>(netmask32-1-n)) & 1
+	}
+	if n < netmask64 {
+		return int(i.set.high>>(netmask64-1-n)) & 1
+	}
+	return int(i.set.low>>(netmask128-1-n)) & 1
+}
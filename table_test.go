@@ -0,0 +1,56 @@
+package ipaddr
+
+import (
+	"testing"
+)
+
+func TestTable(t *testing.T) {
+	var tb Table
+	for _, str := range []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "2001:db8::/32"} {
+		nw, _ := ParseNet(str)
+		tb.Insert(nw, str)
+	}
+	if tb.Len() != 5 {
+		t.Fatalf("length mismatched! want 5, got %d", tb.Len())
+	}
+	data := []struct {
+		Addr string
+		Want string
+	}{
+		{Addr: "10.1.2.3", Want: "10.1.2.0/24"},
+		{Addr: "10.1.3.3", Want: "10.1.0.0/16"},
+		{Addr: "10.2.3.3", Want: "10.0.0.0/8"},
+		{Addr: "192.168.1.1", Want: "0.0.0.0/0"},
+		{Addr: "2001:db8::1", Want: "2001:db8::/32"},
+		{Addr: "2001:db9::1"},
+	}
+	for _, d := range data {
+		ip, _ := ParseIP(d.Addr)
+		nw, v, ok := tb.Lookup(ip)
+		if d.Want == "" {
+			if ok {
+				t.Errorf("%s: unexpected match %s", d.Addr, nw)
+			}
+			continue
+		}
+		if !ok || nw.String() != d.Want || v.(string) != d.Want {
+			t.Errorf("%s: results mismatched! want %s, got %s (%v)", d.Addr, d.Want, nw, v)
+		}
+	}
+	nw, _ := ParseNet("10.1.0.0/16")
+	if !tb.Delete(nw) || tb.Len() != 4 {
+		t.Fatalf("fail to delete %s", nw)
+	}
+	ip, _ := ParseIP("10.1.3.3")
+	if got, _, _ := tb.Lookup(ip); got.String() != "10.0.0.0/8" {
+		t.Errorf("%s: results mismatched after delete! want 10.0.0.0/8, got %s", ip, got)
+	}
+	var list []string
+	tb.Walk(func(n Net, _ interface{}) bool {
+		list = append(list, n.String())
+		return true
+	})
+	if len(list) != 4 || list[0] != "0.0.0.0/0" || list[3] != "2001:db8::/32" {
+		t.Errorf("walk mismatched! got %v", list)
+	}
+}
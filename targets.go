@@ -0,0 +1,383 @@
+package ipaddr
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Targets struct {
+	list []target
+}
+
+func ParseTargets(str string) (*Targets, error) {
+	var t Targets
+	for _, f := range strings.Fields(str) {
+		x, err := parseTarget(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		t.list = append(t.list, x)
+	}
+	if len(t.list) == 0 {
+		return nil, ErrInvalid
+	}
+	return &t, nil
+}
+
+func (t *Targets) Count() *big.Int {
+	n := big.NewInt(0)
+	for _, x := range t.list {
+		n.Add(n, x.count())
+	}
+	return n
+}
+
+func (t *Targets) Nets() []Net {
+	var list []Net
+	for _, x := range t.list {
+		list = append(list, x.nets()...)
+	}
+	return list
+}
+
+func (t *Targets) Iter() *TargetIter {
+	return &TargetIter{
+		list: t.list,
+	}
+}
+
+type TargetIter struct {
+	list []target
+	curr cursor
+	ip   IP
+}
+
+func (i *TargetIter) Next() bool {
+	for {
+		if i.curr == nil {
+			if len(i.list) == 0 {
+				return false
+			}
+			i.curr, i.list = i.list[0].cursor(), i.list[1:]
+		}
+		if ip, ok := i.curr.next(); ok {
+			i.ip = ip
+			return true
+		}
+		i.curr = nil
+	}
+}
+
+func (i *TargetIter) IP() IP {
+	return i.ip
+}
+
+// Summarize returns the minimal list of networks covering exactly the range
+// of addresses from first to last.
+func Summarize(first, last IP) ([]Net, error) {
+	if first.zone == 0 || first.zone != last.zone || last.Less(first) {
+		return nil, ErrInvalid
+	}
+	var (
+		list []Net
+		bits = first.bitLen()
+	)
+	for {
+		size := first.set.zeros()
+		if diff, _ := last.set.sub(first.set); diff.equal(first.limit()) {
+			size = bits
+		} else if diff, _ = diff.add(bitset{low: 1}); diff.len()-1 < size {
+			size = diff.len() - 1
+		}
+		if size > bits {
+			size = bits
+		}
+		nw, err := first.Mask(uint8(bits - size))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, nw)
+		if nw.Last() == last {
+			break
+		}
+		if first, err = nw.Last().Next(); err != nil {
+			break
+		}
+	}
+	return list, nil
+}
+
+type target interface {
+	count() *big.Int
+	nets() []Net
+	cursor() cursor
+}
+
+type cursor interface {
+	next() (IP, bool)
+}
+
+type span struct {
+	lo uint8
+	hi uint8
+}
+
+func (s span) count() int {
+	return int(s.hi) - int(s.lo) + 1
+}
+
+type octetTarget [4][]span
+
+func parseOctets(str string) (target, error) {
+	var (
+		t     octetTarget
+		parts = strings.Split(str, ".")
+	)
+	if len(parts) != 4 {
+		return nil, ErrInvalid
+	}
+	for i := range parts {
+		list, err := parseSpans(parts[i])
+		if err != nil {
+			return nil, err
+		}
+		t[i] = list
+	}
+	return t, nil
+}
+
+func parseSpans(str string) ([]span, error) {
+	var list []span
+	for _, str := range strings.Split(str, ",") {
+		var (
+			s   = span{hi: 255}
+			err error
+		)
+		switch x := strings.Index(str, "-"); {
+		case str == "*" || str == "-":
+		case x < 0:
+			s.lo, err = parseOctet(str)
+			s.hi = s.lo
+		default:
+			if x > 0 {
+				s.lo, err = parseOctet(str[:x])
+			}
+			if err == nil && x < len(str)-1 {
+				s.hi, err = parseOctet(str[x+1:])
+			}
+		}
+		if err != nil || s.lo > s.hi {
+			return nil, ErrInvalid
+		}
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].lo < list[j].lo
+	})
+	var j int
+	for i := 1; i < len(list); i++ {
+		if int(list[i].lo) <= int(list[j].hi)+1 {
+			if list[i].hi > list[j].hi {
+				list[j].hi = list[i].hi
+			}
+			continue
+		}
+		j++
+		list[j] = list[i]
+	}
+	return list[:j+1], nil
+}
+
+func parseOctet(str string) (uint8, error) {
+	n, err := strconv.ParseUint(str, 10, 8)
+	return uint8(n), err
+}
+
+func (t octetTarget) count() *big.Int {
+	n := big.NewInt(1)
+	for i := range t {
+		var c int64
+		for _, s := range t[i] {
+			c += int64(s.count())
+		}
+		n.Mul(n, big.NewInt(c))
+	}
+	return n
+}
+
+func (t octetTarget) nets() []Net {
+	k := len(t) - 1
+	for ; k >= 0; k-- {
+		if len(t[k]) != 1 || t[k][0].count() != 256 {
+			break
+		}
+	}
+	if k < 0 {
+		nw, _ := IPv4(0, 0, 0, 0).Mask(0)
+		return []Net{nw}
+	}
+	var (
+		list []Net
+		head [4]uint8
+		walk func(int)
+	)
+	walk = func(i int) {
+		if i < k {
+			for _, s := range t[i] {
+				for v := int(s.lo); v <= int(s.hi); v++ {
+					head[i] = uint8(v)
+					walk(i + 1)
+				}
+			}
+			return
+		}
+		for _, s := range t[k] {
+			first, last := head, head
+			first[k], last[k] = s.lo, s.hi
+			for j := k + 1; j < len(head); j++ {
+				first[j], last[j] = 0, 255
+			}
+			ns, _ := Summarize(IPv4(first[0], first[1], first[2], first[3]), IPv4(last[0], last[1], last[2], last[3]))
+			list = append(list, ns...)
+		}
+	}
+	walk(0)
+	return list
+}
+
+func (t octetTarget) cursor() cursor {
+	c := octetCursor{
+		spans: t,
+	}
+	for i := range t {
+		c.curr[i] = int(t[i][0].lo)
+	}
+	return &c
+}
+
+type octetCursor struct {
+	spans octetTarget
+	index [4]int
+	curr  [4]int
+	done  bool
+}
+
+func (c *octetCursor) next() (IP, bool) {
+	if c.done {
+		return Zero, false
+	}
+	ip := IPv4(uint8(c.curr[0]), uint8(c.curr[1]), uint8(c.curr[2]), uint8(c.curr[3]))
+	for i := len(c.curr) - 1; ; i-- {
+		if i < 0 {
+			c.done = true
+			break
+		}
+		s := c.spans[i][c.index[i]]
+		if c.curr[i] < int(s.hi) {
+			c.curr[i]++
+			break
+		}
+		if c.index[i] < len(c.spans[i])-1 {
+			c.index[i]++
+			c.curr[i] = int(c.spans[i][c.index[i]].lo)
+			break
+		}
+		c.index[i] = 0
+		c.curr[i] = int(c.spans[i][0].lo)
+	}
+	return ip, true
+}
+
+type rangeTarget struct {
+	first IP
+	last  IP
+}
+
+func (t rangeTarget) count() *big.Int {
+	diff, _ := t.last.set.sub(t.first.set)
+	n := new(big.Int).SetUint64(diff.high)
+	n.Lsh(n, 64)
+	n.Or(n, new(big.Int).SetUint64(diff.low))
+	return n.Add(n, big.NewInt(1))
+}
+
+func (t rangeTarget) nets() []Net {
+	list, _ := Summarize(t.first, t.last)
+	return list
+}
+
+func (t rangeTarget) cursor() cursor {
+	return &rangeCursor{
+		curr: t.first,
+		last: t.last,
+	}
+}
+
+type rangeCursor struct {
+	curr IP
+	last IP
+	done bool
+}
+
+func (c *rangeCursor) next() (IP, bool) {
+	if c.done {
+		return Zero, false
+	}
+	ip := c.curr
+	if next, err := c.curr.Next(); err != nil || ip == c.last {
+		c.done = true
+	} else {
+		c.curr = next
+	}
+	return ip, true
+}
+
+func parseTarget(str string) (target, error) {
+	if strings.Contains(str, "/") {
+		nw, err := ParseNet(str)
+		if err != nil {
+			return nil, err
+		}
+		return rangeTarget{first: nw.Address(), last: nw.Last()}, nil
+	}
+	if x := strings.Index(str, "-"); x > 0 {
+		first, err := ParseIP(str[:x])
+		if err == nil {
+			t, err := parseRange(first, str[x+1:])
+			if err != nil && first.Is4() {
+				return parseOctets(str)
+			}
+			return t, err
+		}
+	}
+	if strings.Contains(str, ":") {
+		ip, err := ParseIP(str)
+		if err != nil {
+			return nil, err
+		}
+		return rangeTarget{first: ip, last: ip}, nil
+	}
+	return parseOctets(str)
+}
+
+func parseRange(first IP, str string) (target, error) {
+	last, err := ParseIP(str)
+	if err != nil {
+		if !first.Is6() {
+			return nil, ErrInvalid
+		}
+		n, err := strconv.ParseUint(str, 16, 16)
+		if err != nil {
+			return nil, ErrInvalid
+		}
+		last = first
+		last.set.low = last.set.low&^0xffff | n
+	}
+	if last.zone != first.zone || last.Less(first) {
+		return nil, ErrInvalid
+	}
+	return rangeTarget{first: first, last: last}, nil
+}
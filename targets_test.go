@@ -0,0 +1,115 @@
+package ipaddr
+
+import (
+	"testing"
+)
+
+func TestParseTargets(t *testing.T) {
+	data := []struct {
+		Expr  string
+		Count int64
+		First string
+		Last  string
+		Nets  []string
+	}{
+		{
+			Expr:  "10.0.0-3.1-254",
+			Count: 4 * 254,
+			First: "10.0.0.1",
+			Last:  "10.0.3.254",
+		},
+		{
+			Expr:  "192.168.*.1",
+			Count: 256,
+			First: "192.168.0.1",
+			Last:  "192.168.255.1",
+		},
+		{
+			Expr:  "10.1.1.1,5,9",
+			Count: 3,
+			First: "10.1.1.1",
+			Last:  "10.1.1.9",
+			Nets:  []string{"10.1.1.1/32", "10.1.1.5/32", "10.1.1.9/32"},
+		},
+		{
+			Expr:  "10.1.1-2.*",
+			Count: 512,
+			First: "10.1.1.0",
+			Last:  "10.1.2.255",
+			Nets:  []string{"10.1.1.0/24", "10.1.2.0/24"},
+		},
+		{
+			Expr:  "2001:db8::1-ff",
+			Count: 255,
+			First: "2001:db8::1",
+			Last:  "2001:db8::ff",
+			Nets: []string{
+				"2001:db8::1/128",
+				"2001:db8::2/127",
+				"2001:db8::4/126",
+				"2001:db8::8/125",
+				"2001:db8::10/124",
+				"2001:db8::20/123",
+				"2001:db8::40/122",
+				"2001:db8::80/121",
+			},
+		},
+		{
+			Expr:  "192.168.1.0/30 10.0.0.1-10.0.0.2",
+			Count: 6,
+			First: "192.168.1.0",
+			Last:  "10.0.0.2",
+			Nets:  []string{"192.168.1.0/30", "10.0.0.1/32", "10.0.0.2/32"},
+		},
+	}
+	for _, d := range data {
+		ts, err := ParseTargets(d.Expr)
+		if err != nil {
+			t.Errorf("%s: fail to parse %s", d.Expr, err)
+			continue
+		}
+		if got := ts.Count(); got.Int64() != d.Count {
+			t.Errorf("%s: count mismatched! want %d, got %s", d.Expr, d.Count, got)
+		}
+		var (
+			it    = ts.Iter()
+			n     int64
+			first IP
+			last  IP
+		)
+		for it.Next() {
+			if n == 0 {
+				first = it.IP()
+			}
+			last = it.IP()
+			n++
+		}
+		if n != d.Count {
+			t.Errorf("%s: iterated addresses mismatched! want %d, got %d", d.Expr, d.Count, n)
+		}
+		if first.String() != d.First || last.String() != d.Last {
+			t.Errorf("%s: bounds mismatched! want %s-%s, got %s-%s", d.Expr, d.First, d.Last, first, last)
+		}
+		if d.Nets == nil {
+			continue
+		}
+		nets := ts.Nets()
+		if len(nets) != len(d.Nets) {
+			t.Errorf("%s: networks mismatched! want %v, got %v", d.Expr, d.Nets, nets)
+			continue
+		}
+		for i := range nets {
+			if nets[i].String() != d.Nets[i] {
+				t.Errorf("%s: networks mismatched at %d! want %s, got %s", d.Expr, i, d.Nets[i], nets[i])
+			}
+		}
+	}
+}
+
+func TestParseTargetsInvalid(t *testing.T) {
+	for _, str := range []string{"", "10.0.0.256", "10.0.5-3.1", "10.0.0", "2001:db8::ff-1", "10.0.0.1-2001:db8::1"} {
+		if _, err := ParseTargets(str); err == nil {
+			t.Errorf("%q: invalid target parsed successfully", str)
+		}
+	}
+}